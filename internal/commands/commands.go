@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"message-pocket/internal/config"
+	"message-pocket/internal/tracing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// defaultOperator 命令行操作记录的操作人
+const defaultOperator = "cli"
+
+// runErr 最近一次子命令执行的错误
+// PocketBase 的 Execute 会忽略命令返回的错误，由 main 根据 Err 设置退出码
+var runErr error
+
+// skipMigrationsAnnotation 标记不访问数据库、执行前无需迁移的命令
+const skipMigrationsAnnotation = "skip_migrations"
+
+// Register 注册运维子命令，与 serve 共用 Inject 创建的 injector
+func Register(root *cobra.Command, i do.Injector) {
+	for _, command := range []*cobra.Command{
+		NewSendCommand(i),
+		NewRetryCommand(i),
+		NewMessagesCommand(i),
+		NewStatsCommand(i),
+		NewConfigCommand(i),
+		NewDestinationsCommand(i),
+		NewTokensCommand(i),
+		NewEncryptionCommand(i),
+	} {
+		wrapRunE(command, i)
+		root.AddCommand(command)
+	}
+}
+
+// Err 返回子命令执行的错误，脚本据此判断是否成功
+func Err() error {
+	return runErr
+}
+
+// wrapRunE 包装命令及其子命令的 RunE：与 serve 一样先执行未应用的迁移，并记录返回的错误
+// 每次执行开始一个新的 trace，便于与服务日志对照
+func wrapRunE(command *cobra.Command, i do.Injector) {
+	if run := command.RunE; run != nil {
+		command.RunE = func(command *cobra.Command, args []string) error {
+			ctx := command.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			ctx, span := tracing.Start(ctx, command.CommandPath())
+			command.SetContext(ctx)
+			defer func() { tracing.End(span, runErr) }()
+
+			// 配置读取失败时不执行命令，避免使用空配置
+			if _, runErr = config.LoadConfig(); runErr != nil {
+				return runErr
+			}
+			if command.Annotations[skipMigrationsAnnotation] == "" {
+				if runErr = do.MustInvoke[core.App](i).RunAllMigrations(); runErr != nil {
+					return fmt.Errorf("failed to apply migrations: %w", runErr)
+				}
+			}
+			runErr = run(command, args)
+			return runErr
+		}
+	}
+	for _, sub := range command.Commands() {
+		wrapRunE(sub, i)
+	}
+}
+
+// commandContext 返回带有本次执行 trace 的 context
+func commandContext(command *cobra.Command) context.Context {
+	return command.Context()
+}
+
+// printJSON 以缩进 JSON 输出，便于脚本用 jq 处理
+func printJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return nil
+}
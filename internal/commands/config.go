@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+	"message-pocket/internal/config"
+	"message-pocket/internal/services"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// NewConfigCommand 配置相关命令
+func NewConfigCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Inspects the configuration",
+	}
+
+	command.AddCommand(configValidateCommand(i))
+
+	return command
+}
+
+func configValidateCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:          "validate",
+		Annotations:  map[string]string{skipMigrationsAnnotation: "true"},
+		Short:        "Validates the config and exits non-zero on errors",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			// 读取失败时与校验错误一样列出，不会 panic
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				return err
+			}
+
+			// 与热加载使用同一套校验
+			if err := services.ValidateConfig(cfg); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(command.OutOrStdout(), "config is valid")
+			return nil
+		},
+	}
+
+	return command
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/services"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// NewDestinationsCommand 目的地相关命令
+func NewDestinationsCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "destinations",
+		Short: "Diagnoses message destinations",
+	}
+
+	command.AddCommand(destinationsTestCommand(i))
+
+	return command
+}
+
+func destinationsTestCommand(i do.Injector) *cobra.Command {
+	var (
+		target string
+		text   string
+	)
+
+	command := &cobra.Command{
+		Use:          "test <name>",
+		Example:      "destinations test qq-group --target 123456",
+		Short:        "Sends a test message directly to a destination without storing it",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing destination name argument")
+			}
+
+			destinationType, ok := message_box_enum.ParseDestinationType(args[0])
+			if !ok {
+				return fmt.Errorf("unknown destination %q", args[0])
+			}
+
+			messageBoxService := do.MustInvoke[*services.MessageBoxService](i)
+			start := time.Now()
+			receipt, err := messageBoxService.TestDestination(commandContext(command), services.TestDestinationRequest{
+				DestinationType: destinationType,
+				Target:          target,
+				Message:         text,
+			})
+			elapsed := time.Since(start).Round(time.Millisecond)
+			if err != nil {
+				return fmt.Errorf("destination %s failed after %s: %w", destinationType, elapsed, err)
+			}
+
+			fmt.Fprintf(command.OutOrStdout(), "destination %s ok in %s, target %s, external id %s\n",
+				destinationType, elapsed, receipt.Target, receipt.ExternalID)
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&target, "target", "", "destination target, e.g. QQ group ID; defaults to the configured one")
+	command.Flags().StringVar(&text, "text", "message-pocket 测试消息", "test message text")
+
+	return command
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"message-pocket/internal/repo"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// NewEncryptionCommand 静态加密相关命令
+func NewEncryptionCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "encryption",
+		Short: "Manages encryption at rest of stored messages",
+	}
+
+	command.AddCommand(encryptionGenerateKeyCommand())
+	command.AddCommand(encryptionRotateCommand(i))
+
+	return command
+}
+
+func encryptionGenerateKeyCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:          "generate-key",
+		Annotations:  map[string]string{skipMigrationsAnnotation: "true"},
+		Short:        "Prints a new random base64 encoded 32 byte master key",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return err
+			}
+			fmt.Fprintln(command.OutOrStdout(), base64.StdEncoding.EncodeToString(key))
+			return nil
+		},
+	}
+
+	return command
+}
+
+func encryptionRotateCommand(i do.Injector) *cobra.Command {
+	var batchSize int
+
+	command := &cobra.Command{
+		Use:          "rotate",
+		Short:        "Re-encrypts all messages with the active key, encrypting plaintext ones",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			// 为 0 时查询不限制条数，数量永远不小于批量大小，循环不会结束
+			if batchSize <= 0 {
+				return fmt.Errorf("--batch must be positive, got %d", batchSize)
+			}
+
+			ctx := commandContext(command)
+			messageBoxRepo := do.MustInvoke[repo.IMessageBoxRepo](i)
+
+			total := 0
+			for {
+				count, err := messageBoxRepo.RotateEncryption(ctx, batchSize)
+				if err != nil {
+					return fmt.Errorf("rotated %d messages before failing: %w", total, err)
+				}
+				total += count
+				if count < batchSize {
+					break
+				}
+			}
+
+			fmt.Fprintf(command.OutOrStdout(), "rotated %d messages\n", total)
+			return nil
+		},
+	}
+
+	command.Flags().IntVar(&batchSize, "batch", 200, "messages to rotate per batch")
+
+	return command
+}
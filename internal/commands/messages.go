@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"fmt"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/services"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// NewMessagesCommand 查询消息
+func NewMessagesCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "messages",
+		Short: "Inspects stored messages",
+	}
+
+	command.AddCommand(messagesListCommand(i))
+
+	return command
+}
+
+func messagesListCommand(i do.Injector) *cobra.Command {
+	var (
+		statuses    []string
+		source      string
+		destination string
+		bizID       string
+		traceID     string
+		keyword     string
+		sort        string
+		limit       int
+		asJSON      bool
+	)
+
+	command := &cobra.Command{
+		Use:          "list",
+		Example:      "messages list --status pending --limit 50",
+		Short:        "Lists messages, newest first",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			req := services.ListMessagesRequest{
+				BizID:   bizID,
+				TraceID: traceID,
+				Keyword: keyword,
+				Sort:    sort,
+				Page:    1,
+				PerPage: limit,
+			}
+			for _, name := range statuses {
+				status, ok := message_box_enum.ParseStatusType(name)
+				if !ok {
+					return fmt.Errorf("unknown status %q", name)
+				}
+				req.Statuses = append(req.Statuses, status)
+			}
+			if source != "" {
+				sourceType, ok := message_box_enum.ParseSourceType(source)
+				if !ok {
+					return fmt.Errorf("unknown source %q", source)
+				}
+				req.SourceType = sourceType
+			}
+			if destination != "" {
+				destinationType, ok := message_box_enum.ParseDestinationType(destination)
+				if !ok {
+					return fmt.Errorf("unknown destination %q", destination)
+				}
+				req.DestinationType = destinationType
+			}
+
+			messageBoxService := do.MustInvoke[*services.MessageBoxService](i)
+			messages, total, err := messageBoxService.ListMessages(commandContext(command), req)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				return printJSON(command.OutOrStdout(), messages)
+			}
+
+			w := tabwriter.NewWriter(command.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATUS\tSEVERITY\tSOURCE\tDESTINATION\tATTEMPTS\tCREATED\tMESSAGE")
+			for _, message := range messages {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+					message.ID,
+					message_box_enum.StatusType(message.Status),
+					message.Severity,
+					message.SourceType,
+					message.DestinationType,
+					message.Attempts,
+					formatUnix(message.CreatedAt),
+					summarize(message.Message),
+				)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(command.OutOrStdout(), "%d of %d messages\n", len(messages), total)
+			return nil
+		},
+	}
+
+	command.Flags().StringSliceVar(&statuses, "status", nil, "filter by status, comma separated: pending, sent, muted, silenced, expired, cancelled")
+	command.Flags().StringVar(&source, "source", "", "filter by source name")
+	command.Flags().StringVar(&destination, "dest", "", "filter by destination name")
+	command.Flags().StringVar(&bizID, "biz-id", "", "filter by business ID")
+	command.Flags().StringVar(&traceID, "trace-id", "", "filter by the trace ID of the request that created the message")
+	command.Flags().StringVarP(&keyword, "query", "q", "", "search in message text")
+	command.Flags().StringVar(&sort, "sort", "", "sort column, prefix with - for descending (default -created_at)")
+	command.Flags().IntVar(&limit, "limit", 20, "maximum number of messages to list")
+	command.Flags().BoolVar(&asJSON, "json", false, "print messages as JSON")
+
+	return command
+}
+
+// formatUnix 将 Unix 秒格式化为本地时间
+func formatUnix(value string) string {
+	var seconds int64
+	if _, err := fmt.Sscan(value, &seconds); err != nil {
+		return value
+	}
+	return formatUnixSeconds(seconds)
+}
+
+// summarize 取消息第一行并截断，避免表格换行
+func summarize(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	runes := []rune(line)
+	if len(runes) > 40 {
+		return string(runes[:40]) + "…"
+	}
+	return line
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"message-pocket/internal/services"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// NewRetryCommand 重试发送失败的消息，指定 --id 时只重发该条消息
+func NewRetryCommand(i do.Injector) *cobra.Command {
+	var messageID string
+
+	command := &cobra.Command{
+		Use:          "retry",
+		Example:      "retry --id r863e095263f832",
+		Short:        "Retries failed messages, or resends a single message with --id",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			ctx := commandContext(command)
+
+			if messageID == "" {
+				// 与重试任务相同：重试创建超过一分钟仍在发送中的消息
+				messageBoxService := do.MustInvoke[*services.MessageBoxService](i)
+				return messageBoxService.MessageRetry(ctx)
+			}
+
+			messageOperationService := do.MustInvoke[*services.MessageOperationService](i)
+			result, err := messageOperationService.Resend(ctx, services.ResendMessageRequest{
+				MessageID:  messageID,
+				OperatedBy: defaultOperator,
+			})
+			if err != nil {
+				return err
+			}
+			return printJSON(command.OutOrStdout(), result)
+		},
+	}
+
+	command.Flags().StringVar(&messageID, "id", "", "message ID to resend")
+
+	return command
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/services"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// NewSendCommand 发送一条消息，与 webhook 一样经过免打扰、限流并保存到消息表
+func NewSendCommand(i do.Injector) *cobra.Command {
+	var (
+		destination string
+		target      string
+		text        string
+		severity    string
+		bizID       string
+	)
+
+	command := &cobra.Command{
+		Use:          "send",
+		Example:      "send --dest qq-group --text \"部署完成\"",
+		Short:        "Saves and sends a message to a destination",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			if text == "" {
+				return errors.New("missing --text")
+			}
+			destinationType, ok := message_box_enum.ParseDestinationType(destination)
+			if !ok {
+				return fmt.Errorf("unknown destination %q", destination)
+			}
+			severityType, ok := message_box_enum.ParseSeverity(severity)
+			if !ok {
+				return fmt.Errorf("unknown severity %q", severity)
+			}
+
+			ctx := commandContext(command)
+			messageBoxService := do.MustInvoke[*services.MessageBoxService](i)
+			messageBox, sendErr := messageBoxService.SaveAndSendMessage(ctx, services.SaveMessageRequest{
+				BizID:           bizID,
+				Message:         text,
+				SourceRequest:   "{}",
+				SourceType:      message_box_enum.SourceTypeCLI,
+				DestinationType: destinationType,
+				Severity:        severityType,
+				Target:          target,
+			})
+			if messageBox == nil {
+				return sendErr
+			}
+
+			// 重新查询以输出发送后的状态、次数和回执
+			current, err := messageBoxService.GetMessage(ctx, messageBox.ID)
+			if err != nil {
+				return err
+			}
+			if current != nil {
+				messageBox = current
+			}
+			if err := printJSON(command.OutOrStdout(), messageBox); err != nil {
+				return err
+			}
+
+			// 消息已保存，发送失败时由重试任务继续发送
+			return sendErr
+		},
+	}
+
+	command.Flags().StringVar(&destination, "dest", message_box_enum.DestinationQQGroup.String(), "destination name")
+	command.Flags().StringVar(&target, "target", "", "destination target, e.g. QQ group ID; defaults to the configured one")
+	command.Flags().StringVar(&text, "text", "", "message text")
+	command.Flags().StringVar(&severity, "severity", message_box_enum.SeverityInfo.String(), "message severity: info, warning or critical")
+	command.Flags().StringVar(&bizID, "biz-id", "", "business ID for searching the message later")
+
+	return command
+}
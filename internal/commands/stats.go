@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"message-pocket/internal/services"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/samber/do/v2"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+// NewStatsCommand 按状态和目的地统计消息数
+func NewStatsCommand(i do.Injector) *cobra.Command {
+	var asJSON bool
+
+	command := &cobra.Command{
+		Use:          "stats",
+		Short:        "Prints message counts by destination and status",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			messageBoxService := do.MustInvoke[*services.MessageBoxService](i)
+			stats, err := messageBoxService.Stats(commandContext(command))
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				return printJSON(command.OutOrStdout(), stats)
+			}
+
+			w := tabwriter.NewWriter(command.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "DESTINATION\tSTATUS\tCOUNT")
+			destinations := lo.Keys(stats.ByDestination)
+			slices.Sort(destinations)
+			for _, destination := range destinations {
+				statuses := lo.Keys(stats.ByDestination[destination])
+				slices.Sort(statuses)
+				for _, status := range statuses {
+					fmt.Fprintf(w, "%s\t%s\t%d\n", destination, status, stats.ByDestination[destination][status])
+				}
+			}
+			fmt.Fprintf(w, "total\t\t%d\n", stats.Total)
+			return w.Flush()
+		},
+	}
+
+	command.Flags().BoolVar(&asJSON, "json", false, "print stats as JSON")
+
+	return command
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"message-pocket/internal/services"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+)
+
+// NewTokensCommand 管理 API 令牌
+func NewTokensCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "tokens",
+		Short: "Manages API tokens",
+	}
+
+	command.AddCommand(tokensCreateCommand(i))
+	command.AddCommand(tokensListCommand(i))
+	command.AddCommand(tokensRotateCommand(i))
+	command.AddCommand(tokensRevokeCommand(i))
+
+	return command
+}
+
+func tokensCreateCommand(i do.Injector) *cobra.Command {
+	var (
+		name      string
+		scopes    []string
+		expiresIn time.Duration
+	)
+
+	command := &cobra.Command{
+		Use:          "create",
+		Example:      "tokens create --name edgeone --scope source:eo --expires-in 8760h",
+		Short:        "Creates a token and prints its secret once",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			req := services.CreateTokenRequest{
+				Name:      name,
+				Scopes:    scopes,
+				CreatedBy: defaultOperator,
+			}
+			if expiresIn > 0 {
+				req.ExpiresAt = time.Now().Add(expiresIn)
+			}
+
+			tokenService := do.MustInvoke[*services.TokenService](i)
+			token, err := tokenService.CreateToken(commandContext(command), req)
+			if err != nil {
+				return err
+			}
+			return printJSON(command.OutOrStdout(), token)
+		},
+	}
+
+	command.Flags().StringVar(&name, "name", "", "unique token name, e.g. the upstream system using it")
+	command.Flags().StringSliceVar(&scopes, "scope", nil, "allowed scopes, comma separated: source:eo, send, admin")
+	command.Flags().DurationVar(&expiresIn, "expires-in", 0, "token lifetime, e.g. 720h; never expires when omitted")
+
+	return command
+}
+
+func tokensListCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:          "list",
+		Short:        "Lists tokens",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			tokenService := do.MustInvoke[*services.TokenService](i)
+			tokens, err := tokenService.ListTokens(commandContext(command))
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(command.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tEXPIRES\tLAST USED\tREVOKED")
+			for _, token := range tokens {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					token.ID,
+					token.Name,
+					token.TokenPrefix,
+					strings.Join(token.Scopes, ","),
+					formatUnixSeconds(token.ExpiresAt),
+					formatUnixSeconds(token.LastUsedAt),
+					formatUnixSeconds(token.RevokedAt),
+				)
+			}
+			return w.Flush()
+		},
+	}
+
+	return command
+}
+
+func tokensRotateCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:          "rotate <id>",
+		Short:        "Issues a new secret for a token, invalidating the old one",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing token id argument")
+			}
+
+			tokenService := do.MustInvoke[*services.TokenService](i)
+			token, err := tokenService.RotateToken(commandContext(command), args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(command.OutOrStdout(), token)
+		},
+	}
+
+	return command
+}
+
+func tokensRevokeCommand(i do.Injector) *cobra.Command {
+	command := &cobra.Command{
+		Use:          "revoke <id>",
+		Short:        "Revokes a token",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing token id argument")
+			}
+
+			tokenService := do.MustInvoke[*services.TokenService](i)
+			token, err := tokenService.RevokeToken(commandContext(command), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(command.OutOrStdout(), "token %s (%s) revoked\n", token.ID, token.Name)
+			return nil
+		},
+	}
+
+	return command
+}
+
+// formatUnixSeconds 将 Unix 秒格式化为本地时间，零值输出 -
+func formatUnixSeconds(seconds int64) string {
+	if seconds == 0 {
+		return "-"
+	}
+	return time.Unix(seconds, 0).Format(time.DateTime)
+}
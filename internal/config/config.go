@@ -2,10 +2,16 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/samber/lo"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
@@ -13,60 +19,415 @@ import (
 type Config struct {
 	ServerConfig ServerConfig `yaml:"server" mapstructure:"server"`
 	NapCatConfig NapCatConfig `yaml:"napcat" mapstructure:"napcat"`
+	// RateLimit 按目的地名称配置的限流规则，key 如 qq-group
+	RateLimit map[string]RateLimitConfig `yaml:"rate_limit" mapstructure:"rate_limit"`
+	// CircuitBreaker 按目的地名称配置的熔断规则，key 如 qq-group
+	CircuitBreaker map[string]CircuitBreakerConfig `yaml:"circuit_breaker" mapstructure:"circuit_breaker"`
+	// Retry 发送失败后的重试配置
+	Retry RetryConfig `yaml:"retry" mapstructure:"retry"`
+	// Mute 免打扰配置
+	Mute MuteConfig `yaml:"mute" mapstructure:"mute"`
+	// Severity 按来源和事件类型映射严重级别的规则，按顺序匹配，优先于内置默认映射
+	Severity []SeverityRule `yaml:"severity" mapstructure:"severity"`
+	// IPAccess 按路由分组的 IP 访问控制
+	IPAccess IPAccessConfig `yaml:"ip_access" mapstructure:"ip_access"`
+	// Encryption 消息内容和原始请求的静态加密
+	Encryption EncryptionConfig `yaml:"encryption" mapstructure:"encryption"`
+	// Redaction 日志和原始请求的脱敏规则
+	Redaction RedactionConfig `yaml:"redaction" mapstructure:"redaction"`
+	// Tracing OpenTelemetry 链路追踪
+	Tracing TracingConfig `yaml:"tracing" mapstructure:"tracing"`
+	// Watchdog 发送异常的自监控告警
+	Watchdog WatchdogConfig `yaml:"watchdog" mapstructure:"watchdog"`
 }
 
 type ServerConfig struct {
-	OpenToken string `yaml:"open_token" mapstructure:"open_token"`
+	OpenToken string `yaml:"open_token" mapstructure:"open_token" redact:"true"`
+	// AuthLockout 同一 IP 连续认证失败后的锁定规则
+	AuthLockout AuthLockoutConfig `yaml:"auth_lockout" mapstructure:"auth_lockout"`
+	// WebhookTolerance 来源 webhook 的事件时间与服务器时间允许的偏差，超出时拒绝请求，零值使用默认值，小于 0 表示不检查
+	WebhookTolerance time.Duration `yaml:"webhook_tolerance" mapstructure:"webhook_tolerance"`
+}
+
+// AuthLockoutConfig 认证失败锁定配置，零值使用默认值
+type AuthLockoutConfig struct {
+	// MaxFailures 时间窗口内允许的失败次数，小于 0 表示不锁定
+	MaxFailures int `yaml:"max_failures" mapstructure:"max_failures"`
+	// Window 统计失败次数的时间窗口
+	Window time.Duration `yaml:"window" mapstructure:"window"`
+	// Duration 锁定时长
+	Duration time.Duration `yaml:"duration" mapstructure:"duration"`
 }
 
 type NapCatConfig struct {
 	URL     string `yaml:"url" mapstructure:"url"`
-	Token   string `yaml:"token" mapstructure:"token"`
+	Token   string `yaml:"token" mapstructure:"token" redact:"true"`
 	GroupID string `yaml:"group_id" mapstructure:"group_id"`
+	// Timeout 调用 NapCat 接口的超时，零值使用默认值
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// RateLimitConfig 目的地限流配置
+type RateLimitConfig struct {
+	// Rate 目的地整体每秒补充的令牌数，<=0 表示不限制
+	Rate  float64 `yaml:"rate" mapstructure:"rate"`
+	Burst int     `yaml:"burst" mapstructure:"burst"`
+	// MaxWait 令牌不足时最多等待的时长，超过则延后到重试任务发送
+	MaxWait time.Duration `yaml:"max_wait" mapstructure:"max_wait"`
+	// PerTarget 每个发送目标（如群号）的默认限流规则
+	PerTarget RateLimitRule `yaml:"per_target" mapstructure:"per_target"`
+	// Targets 按发送目标单独覆盖的限流规则
+	Targets map[string]RateLimitRule `yaml:"targets" mapstructure:"targets"`
+}
+
+// RateLimitRule 令牌桶规则
+type RateLimitRule struct {
+	Rate  float64 `yaml:"rate" mapstructure:"rate"`
+	Burst int     `yaml:"burst" mapstructure:"burst"`
+}
+
+// CircuitBreakerConfig 目的地熔断配置，按目的地和发送目标分别统计，零值字段使用默认值
+type CircuitBreakerConfig struct {
+	// FailureThreshold 连续失败多少次后熔断，小于 0 表示不熔断
+	FailureThreshold int `yaml:"failure_threshold" mapstructure:"failure_threshold"`
+	// OpenTimeout 熔断后经过多久进入半开状态试探发送
+	OpenTimeout time.Duration `yaml:"open_timeout" mapstructure:"open_timeout"`
+	// HalfOpenMaxProbes 半开状态允许同时进行的试探发送数
+	HalfOpenMaxProbes int `yaml:"half_open_max_probes" mapstructure:"half_open_max_probes"`
+	// SuccessThreshold 半开状态连续成功多少次后恢复
+	SuccessThreshold int `yaml:"success_threshold" mapstructure:"success_threshold"`
+}
+
+// RetryConfig 发送失败重试配置，零值使用默认值
+type RetryConfig struct {
+	// MaxAttempts 最多发送次数，达到后转为死信不再重试，小于 0 表示不限制
+	MaxAttempts int `yaml:"max_attempts" mapstructure:"max_attempts"`
+}
+
+// MuteConfig 免打扰配置
+type MuteConfig struct {
+	Schedules []MuteSchedule `yaml:"schedules" mapstructure:"schedules"`
+}
+
+// MuteSchedule 免打扰时间段，时间段内低于 MinSeverity 的消息暂存，结束后合并为摘要发送
+type MuteSchedule struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	// Destinations 生效的目的地名称，为空表示全部目的地
+	Destinations []string `yaml:"destinations" mapstructure:"destinations"`
+	// Timezone IANA 时区名称，为空使用本地时区
+	Timezone string `yaml:"timezone" mapstructure:"timezone"`
+	// Start 和 End 为 HH:MM 格式，End 早于 Start 表示跨天
+	Start string `yaml:"start" mapstructure:"start"`
+	End   string `yaml:"end" mapstructure:"end"`
+	// Weekdays 生效的星期（以时间段开始的那天为准），如 mon、sat，为空表示每天
+	Weekdays []string `yaml:"weekdays" mapstructure:"weekdays"`
+	// MinSeverity 不低于该级别的消息不受免打扰影响，默认 critical
+	MinSeverity string `yaml:"min_severity" mapstructure:"min_severity"`
+}
+
+// IPAccessConfig IP 访问控制配置
+type IPAccessConfig struct {
+	// TrustedProxies 可信代理的 CIDR，只有来自这些地址的请求才按 PocketBase 的可信代理设置读取 X-Forwarded-For 等请求头，
+	// 为空时与 PocketBase 一致，始终按其设置读取
+	TrustedProxies []string `yaml:"trusted_proxies" mapstructure:"trusted_proxies"`
+	// Groups 按路由分组配置的规则，key 为 source、send、admin、metrics、status；
+	// 未配置的分组不限制来源，metrics 未配置时拒绝全部来源
+	Groups map[string]IPAccessRule `yaml:"groups" mapstructure:"groups"`
+}
+
+// IPAccessRule 路由分组的 IP 规则，先匹配 Deny，Allow 不为空时只允许其中的地址
+type IPAccessRule struct {
+	Allow []string `yaml:"allow" mapstructure:"allow"`
+	Deny  []string `yaml:"deny" mapstructure:"deny"`
+	// AllowFiles 从本地文件导入允许的地址，支持每行一个 CIDR 的文本文件，
+	// 以及 GitHub /meta 接口返回的 JSON（读取其中的 hooks 字段）
+	AllowFiles []string `yaml:"allow_files" mapstructure:"allow_files"`
+}
+
+// EncryptionConfig 静态加密配置
+type EncryptionConfig struct {
+	// ActiveKey 加密新数据使用的主密钥 ID，为空时不加密
+	ActiveKey string `yaml:"active_key" mapstructure:"active_key"`
+	// Keys 主密钥，轮换后旧密钥需要保留到执行完轮换命令
+	Keys []EncryptionKey `yaml:"keys" mapstructure:"keys"`
+}
+
+// EncryptionKey 主密钥，base64 编码的 32 字节，从环境变量或文件读取
+type EncryptionKey struct {
+	ID   string `yaml:"id" mapstructure:"id"`
+	Env  string `yaml:"env" mapstructure:"env"`
+	File string `yaml:"file" mapstructure:"file"`
+}
+
+// RedactionConfig 脱敏配置，字段名忽略大小写和 _ - . 分隔符，以配置的名称结尾即视为敏感
+// 内置 token、password、secret、authorization 等字段，以及 Bearer 令牌和 API 令牌的正则
+type RedactionConfig struct {
+	// Keys 追加的敏感字段名，作用于日志属性和结构体字段
+	Keys []string `yaml:"keys" mapstructure:"keys"`
+	// Patterns 追加的正则，匹配的内容会被替换
+	Patterns []string `yaml:"patterns" mapstructure:"patterns"`
+	// SourceRequest 保存消息前对原始请求脱敏
+	SourceRequest SourceRequestRedactionConfig `yaml:"source_request" mapstructure:"source_request"`
+}
+
+// SourceRequestRedactionConfig 原始请求的脱敏规则，在日志规则的基础上追加
+type SourceRequestRedactionConfig struct {
+	Enabled  bool     `yaml:"enabled" mapstructure:"enabled"`
+	Keys     []string `yaml:"keys" mapstructure:"keys"`
+	Patterns []string `yaml:"patterns" mapstructure:"patterns"`
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置
+type TracingConfig struct {
+	// Exporter 导出方式：otlp、stdout，为空时只生成 trace 用于日志关联，不导出
+	Exporter string `yaml:"exporter" mapstructure:"exporter"`
+	// Endpoint OTLP HTTP 地址，如 localhost:4318，为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 环境变量或默认值
+	Endpoint string `yaml:"endpoint" mapstructure:"endpoint"`
+	// Insecure 使用 HTTP 而非 HTTPS 连接 Endpoint
+	Insecure    bool   `yaml:"insecure" mapstructure:"insecure"`
+	ServiceName string `yaml:"service_name" mapstructure:"service_name"`
+	// SampleRatio 新 trace 的采样比例，取值 (0, 1]，默认 1；带 traceparent 的请求沿用上游的采样决定
+	SampleRatio float64 `yaml:"sample_ratio" mapstructure:"sample_ratio"`
+}
+
+// WatchdogConfig 自监控配置，检测到积压、死信或熔断时通过备用渠道告警，零值字段使用默认值
+type WatchdogConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// BacklogThreshold 目的地待发送消息数达到该值时告警，小于 0 表示不检查
+	BacklogThreshold int64 `yaml:"backlog_threshold" mapstructure:"backlog_threshold"`
+	// BacklogGrowthChecks 待发送消息数连续增长多少次检查后告警，小于 0 表示不检查
+	BacklogGrowthChecks int `yaml:"backlog_growth_checks" mapstructure:"backlog_growth_checks"`
+	// DeadLetterQuietPeriod 多久没有新的死信后视为恢复
+	DeadLetterQuietPeriod time.Duration `yaml:"dead_letter_quiet_period" mapstructure:"dead_letter_quiet_period"`
+	// Email 告警邮件，使用 PocketBase 管理后台配置的 SMTP 发送
+	Email WatchdogEmailConfig `yaml:"email" mapstructure:"email"`
+	// Webhook 告警 webhook，以 JSON POST 发送
+	Webhook WatchdogWebhookConfig `yaml:"webhook" mapstructure:"webhook"`
+}
+
+// WatchdogEmailConfig 告警邮件配置
+type WatchdogEmailConfig struct {
+	To []string `yaml:"to" mapstructure:"to"`
 }
 
+// WatchdogWebhookConfig 告警 webhook 配置
+type WatchdogWebhookConfig struct {
+	URL string `yaml:"url" mapstructure:"url"`
+	// Headers 附加的请求头，如 Authorization
+	Headers map[string]string `yaml:"headers" mapstructure:"headers" redact:"true"`
+}
+
+// SeverityRule 严重级别映射规则
+type SeverityRule struct {
+	// Source 来源名称，如 eo
+	Source string `yaml:"source" mapstructure:"source"`
+	// EventTypes 匹配的事件类型，为空表示该来源的全部事件
+	EventTypes []string `yaml:"event_types" mapstructure:"event_types"`
+	Severity   string   `yaml:"severity" mapstructure:"severity"`
+}
+
+// envPrefix 环境变量前缀，配置项的路径转为大写并以 _ 连接，如 MP_NAPCAT_TOKEN 覆盖 napcat.token
+const envPrefix = "MP"
+
+// fileEnvSuffix 环境变量名加上该后缀时，值为存放配置值的文件路径，用于 Docker/Kubernetes secrets
+const fileEnvSuffix = "_FILE"
+
 var (
-	instance *Config
-	once     sync.Once
+	// current 当前生效的配置，热加载时整体替换
+	current atomic.Pointer[Config]
+	// loadErr 首次加载的错误
+	loadErr error
+	once    sync.Once
+	// reloadMu 保证同一时间只有一次热加载
+	reloadMu sync.Mutex
+	// files 配置文件路径，按顺序合并，为空时读取 ./config.yaml
+	files []string
 )
 
-// LoadConfig 从环境变量加载配置
-func LoadConfig() (*Config, error) {
-	var err error
-	once.Do(func() {
-		// 初始化viper，只使用环境变量
-		v := viper.New()
+// SetFiles 设置配置文件路径，多个文件按顺序合并，后面文件中的配置项覆盖前面的；需要在首次加载配置前调用
+func SetFiles(paths ...string) {
+	files = paths
+}
+
+// newViper 创建读取环境变量的 viper 实例
+func newViper() *viper.Viper {
+	v := viper.New()
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	return v
+}
+
+// readConfig 读取并合并配置文件，再用环境变量和 secrets 文件覆盖，绑定到新的结构体；
+// 只检查能否读取，配置项由 Validate 校验
+func readConfig() (*Config, error) {
+	cfg := &Config{}
+	v := newViper()
+	if len(files) == 0 {
 		v.AddConfigPath("./")
 		v.SetConfigName("config")
 		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			// 默认配置文件不存在时只使用环境变量
+			var notFound viper.ConfigFileNotFoundError
+			if !errors.As(err, &notFound) {
+				return cfg, err
+			}
+		}
+	} else {
+		for _, path := range files {
+			v.SetConfigFile(path)
+			if err := v.MergeInConfig(); err != nil {
+				return cfg, fmt.Errorf("failed to read config file %s: %w", path, err)
+			}
+		}
+	}
 
-		// 将配置绑定到结构体
-		instance = &Config{}
-		if err = v.ReadInConfig(); err != nil {
-			return
+	if err := bindEnvs(v, reflect.TypeOf(Config{}), ""); err != nil {
+		return cfg, err
+	}
+	if err := v.Unmarshal(cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// bindEnvs 为结构体中的每个配置项绑定环境变量，配置文件中没有的配置项也能通过环境变量设置；
+// 设置了 _FILE 环境变量时读取文件内容作为配置值。map 和结构体列表只能在配置文件中设置
+func bindEnvs(v *viper.Viper, t reflect.Type, prefix string) error {
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
 		}
-		if err = v.Unmarshal(instance); err != nil {
-			return
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
 		}
 
-		if isEmpty := lo.IsEmpty(*instance); isEmpty {
-			err = errors.New("some config is empty")
-			return
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			if err := bindEnvs(v, field.Type, key); err != nil {
+				return err
+			}
+			continue
+		case reflect.Map:
+			continue
+		case reflect.Slice:
+			if field.Type.Elem().Kind() != reflect.String {
+				continue
+			}
 		}
 
-		slog.Debug("config loaded success.", instance)
-	})
-	return instance, err
+		if err := v.BindEnv(key); err != nil {
+			return err
+		}
+		if err := bindFileEnv(v, key); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// GetConfig 获取配置实例
-func GetConfig() *Config {
-	if instance == nil {
-		cfg, err := LoadConfig()
+// bindFileEnv 读取配置项对应的 _FILE 环境变量指向的文件，去掉末尾换行后作为配置值
+func bindFileEnv(v *viper.Viper, key string) error {
+	env := envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	path, ok := os.LookupEnv(env + fileEnvSuffix)
+	if !ok {
+		return nil
+	}
+	if _, ok := os.LookupEnv(env); ok {
+		return fmt.Errorf("both %s and %s are set", env, env+fileEnvSuffix)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", env+fileEnvSuffix, err)
+	}
+	v.Set(key, strings.TrimRight(string(content), "\r\n"))
+	return nil
+}
+
+// LoadConfig 从配置文件和环境变量加载配置，只在首次调用时读取。
+// 读取失败时返回空配置和 ValidationError，与校验错误以同样的格式输出
+func LoadConfig() (*Config, error) {
+	once.Do(func() {
+		cfg, err := readConfig()
+		current.Store(cfg)
+
 		if err != nil {
-			panic(err)
+			loadErr = &ValidationError{Errors: []error{err}}
+			return
 		}
-		return cfg
+		slog.Debug("config loaded success.", "config", cfg)
+	})
+	return current.Load(), loadErr
+}
+
+// GetConfig 获取当前生效的配置，热加载后返回新的配置，调用方应在每次使用时获取而不是长期持有
+func GetConfig() *Config {
+	cfg, err := LoadConfig()
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// watchFiles 需要监听的配置文件，未指定时为存在的 ./config.yaml
+func watchFiles() []string {
+	if len(files) > 0 {
+		return files
+	}
+	v := viper.New()
+	v.AddConfigPath("./")
+	v.SetConfigName("config")
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil
+	}
+	return []string{v.ConfigFileUsed()}
+}
+
+// Watch 监听配置文件的变化，任一文件变化时重新读取全部配置，经 validate 校验通过后原子替换并调用 apply；
+// 读取或校验失败时保留原配置并记录日志。环境变量和 secrets 文件不监听，在下次重新读取时生效
+func Watch(validate func(cfg *Config) error, apply func(cfg *Config)) {
+	paths := watchFiles()
+	if len(paths) == 0 {
+		slog.Info("No config file to watch, hot reload disabled")
+		return
+	}
+
+	for _, path := range paths {
+		v := viper.New()
+		v.SetConfigFile(path)
+		v.OnConfigChange(func(event fsnotify.Event) {
+			Reload(validate, apply)
+		})
+		v.WatchConfig()
+	}
+}
+
+// Reload 重新读取配置，校验通过后替换当前配置，返回是否已替换
+func Reload(validate func(cfg *Config) error, apply func(cfg *Config)) bool {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	// 使用新的 viper 实例读取，避免文件写入一半时残留上一次的值
+	cfg, err := readConfig()
+	if err == nil && validate != nil {
+		err = validate(cfg)
+	}
+	if err != nil {
+		slog.Error("Config reload rejected, keeping current config", "err", err)
+		return false
+	}
+
+	current.Store(cfg)
+	slog.Info("Config reloaded")
+	slog.Debug("config loaded success.", "config", cfg)
+	if apply != nil {
+		apply(cfg)
 	}
-	return instance
+	return true
 }
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"message-pocket/internal/constants/message_box_enum"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FieldError 配置项的校验错误，Path 为配置项在 YAML 中的路径，如 napcat.group_id、mute.schedules[0].start
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError 配置校验发现的全部错误，每项一行
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	header := fmt.Sprintf("config has %d errors:", len(e.Errors))
+	if len(e.Errors) == 1 {
+		header = "config has 1 error:"
+	}
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, header)
+	for _, err := range e.Errors {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// Validator 收集校验错误，遇到错误不中断，便于一次列出全部问题
+type Validator struct {
+	errs []error
+}
+
+// Add 记录配置项的错误
+func (v *Validator) Add(path string, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Path: path, Err: fmt.Errorf(format, args...)})
+}
+
+// AddError 记录配置项的错误，err 为 nil 时忽略；path 为空时原样记录
+func (v *Validator) AddError(path string, err error) {
+	if err == nil {
+		return
+	}
+	if path == "" {
+		v.Join(err)
+		return
+	}
+	v.errs = append(v.errs, &FieldError{Path: path, Err: err})
+}
+
+// Join 追加另一次校验的错误，ValidationError 和 errors.Join 的结果展开为其中的各项错误
+func (v *Validator) Join(err error) {
+	if err == nil {
+		return
+	}
+	// 先展开多个错误，避免 errors.As 只取出其中的 ValidationError 而丢掉其他错误
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, item := range joined.Unwrap() {
+			v.Join(item)
+		}
+		return
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		v.errs = append(v.errs, validationErr.Errors...)
+		return
+	}
+	v.errs = append(v.errs, err)
+}
+
+// Err 没有错误时返回 nil，否则返回包含全部错误的 ValidationError
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// Validate 校验配置的结构：必填项、URL 格式、取值范围和重复的名称，返回全部错误。
+// 时间段、CIDR、密钥等需要解析的配置由使用它们的服务校验
+func Validate(cfg *Config) error {
+	v := &Validator{}
+
+	// qq-group 目的地始终启用，需要 NapCat 的地址和默认群号
+	if cfg.NapCatConfig.URL == "" {
+		v.Add("napcat.url", "is required")
+	} else {
+		validateURL(v, "napcat.url", cfg.NapCatConfig.URL)
+	}
+	if cfg.NapCatConfig.GroupID == "" {
+		v.Add("napcat.group_id", "is required")
+	} else if !isDigits(cfg.NapCatConfig.GroupID) {
+		v.Add("napcat.group_id", "%q is not a QQ group number", cfg.NapCatConfig.GroupID)
+	}
+	validateNonNegative(v, "napcat.timeout", cfg.NapCatConfig.Timeout)
+
+	validateNonNegative(v, "server.auth_lockout.window", cfg.ServerConfig.AuthLockout.Window)
+	validateNonNegative(v, "server.auth_lockout.duration", cfg.ServerConfig.AuthLockout.Duration)
+
+	// map 按 key 排序，错误的顺序保持稳定
+	for _, name := range slices.Sorted(maps.Keys(cfg.RateLimit)) {
+		rule := cfg.RateLimit[name]
+		path := "rate_limit." + name
+		validateDestination(v, path, name)
+		if rule.Burst < 0 {
+			v.Add(path+".burst", "must not be negative")
+		}
+		validateNonNegative(v, path+".max_wait", rule.MaxWait)
+		if rule.PerTarget.Burst < 0 {
+			v.Add(path+".per_target.burst", "must not be negative")
+		}
+		for _, target := range slices.Sorted(maps.Keys(rule.Targets)) {
+			if rule.Targets[target].Burst < 0 {
+				v.Add(path+".targets."+target+".burst", "must not be negative")
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(cfg.CircuitBreaker)) {
+		rule := cfg.CircuitBreaker[name]
+		path := "circuit_breaker." + name
+		validateDestination(v, path, name)
+		validateNonNegative(v, path+".open_timeout", rule.OpenTimeout)
+		if rule.HalfOpenMaxProbes < 0 {
+			v.Add(path+".half_open_max_probes", "must not be negative")
+		}
+		if rule.SuccessThreshold < 0 {
+			v.Add(path+".success_threshold", "must not be negative")
+		}
+	}
+
+	names := make(map[string]int, len(cfg.Mute.Schedules))
+	for idx, item := range cfg.Mute.Schedules {
+		path := fmt.Sprintf("mute.schedules[%d].name", idx)
+		if item.Name == "" {
+			v.Add(path, "is required")
+			continue
+		}
+		if first, ok := names[item.Name]; ok {
+			v.Add(path, "duplicate name %q, already used by mute.schedules[%d]", item.Name, first)
+			continue
+		}
+		names[item.Name] = idx
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		v.Add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+	if strings.Contains(cfg.Tracing.Endpoint, "://") {
+		v.Add("tracing.endpoint", "must be host:port without scheme, got %q", cfg.Tracing.Endpoint)
+	}
+
+	validateNonNegative(v, "watchdog.dead_letter_quiet_period", cfg.Watchdog.DeadLetterQuietPeriod)
+	if cfg.Watchdog.Webhook.URL != "" {
+		validateURL(v, "watchdog.webhook.url", cfg.Watchdog.Webhook.URL)
+	}
+
+	return v.Err()
+}
+
+// validateURL 校验 http 或 https 的绝对地址
+func validateURL(v *Validator, path string, raw string) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		v.Add(path, "invalid URL %q", raw)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		v.Add(path, "%q must start with http:// or https://", raw)
+		return
+	}
+	if u.Host == "" {
+		v.Add(path, "%q has no host", raw)
+	}
+}
+
+func validateDestination(v *Validator, path string, name string) {
+	if _, ok := message_box_enum.ParseDestinationType(name); !ok {
+		v.Add(path, "unknown destination %q", name)
+	}
+}
+
+func validateNonNegative(v *Validator, path string, d time.Duration) {
+	if d < 0 {
+		v.Add(path, "must not be negative")
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
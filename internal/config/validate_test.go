@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func validConfig() *Config {
+	return &Config{
+		NapCatConfig: NapCatConfig{
+			URL:     "http://127.0.0.1:3000",
+			GroupID: "123456",
+		},
+		Tracing: TracingConfig{SampleRatio: 1},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name: "napcat required",
+			modify: func(cfg *Config) {
+				cfg.NapCatConfig = NapCatConfig{}
+			},
+			want: []string{
+				"napcat.url: is required",
+				"napcat.group_id: is required",
+			},
+		},
+		{
+			name: "napcat format",
+			modify: func(cfg *Config) {
+				cfg.NapCatConfig.URL = "ws://127.0.0.1:3000"
+				cfg.NapCatConfig.GroupID = "group"
+			},
+			want: []string{
+				`napcat.url: "ws://127.0.0.1:3000" must start with http:// or https://`,
+				`napcat.group_id: "group" is not a QQ group number`,
+			},
+		},
+		{
+			name: "invalid url",
+			modify: func(cfg *Config) {
+				cfg.NapCatConfig.URL = "127.0.0.1:3000"
+			},
+			want: []string{`napcat.url: invalid URL "127.0.0.1:3000"`},
+		},
+		{
+			name: "url without host",
+			modify: func(cfg *Config) {
+				cfg.NapCatConfig.URL = "http://"
+			},
+			want: []string{`napcat.url: "http://" has no host`},
+		},
+		{
+			name: "rate limit and circuit breaker",
+			modify: func(cfg *Config) {
+				cfg.RateLimit = map[string]RateLimitConfig{
+					"qq-group": {
+						Burst:     -1,
+						MaxWait:   -time.Second,
+						PerTarget: RateLimitRule{Burst: -1},
+						Targets:   map[string]RateLimitRule{"654321": {Burst: -1}},
+					},
+					"email": {},
+				}
+				cfg.CircuitBreaker = map[string]CircuitBreakerConfig{
+					"qq-group": {OpenTimeout: -time.Second, HalfOpenMaxProbes: -1, SuccessThreshold: -1},
+				}
+			},
+			want: []string{
+				`rate_limit.email: unknown destination "email"`,
+				"rate_limit.qq-group.burst: must not be negative",
+				"rate_limit.qq-group.max_wait: must not be negative",
+				"rate_limit.qq-group.per_target.burst: must not be negative",
+				"rate_limit.qq-group.targets.654321.burst: must not be negative",
+				"circuit_breaker.qq-group.open_timeout: must not be negative",
+				"circuit_breaker.qq-group.half_open_max_probes: must not be negative",
+				"circuit_breaker.qq-group.success_threshold: must not be negative",
+			},
+		},
+		{
+			name: "mute schedule names",
+			modify: func(cfg *Config) {
+				cfg.Mute.Schedules = []MuteSchedule{{Name: "night"}, {}, {Name: "night"}}
+			},
+			want: []string{
+				"mute.schedules[1].name: is required",
+				`mute.schedules[2].name: duplicate name "night", already used by mute.schedules[0]`,
+			},
+		},
+		{
+			name: "tracing and watchdog",
+			modify: func(cfg *Config) {
+				cfg.Tracing.SampleRatio = 1.5
+				cfg.Tracing.Endpoint = "http://localhost:4318"
+				cfg.Watchdog.DeadLetterQuietPeriod = -time.Minute
+				cfg.Watchdog.Webhook.URL = "ftp://example.com"
+			},
+			want: []string{
+				"tracing.sample_ratio: must be between 0 and 1",
+				`tracing.endpoint: must be host:port without scheme, got "http://localhost:4318"`,
+				"watchdog.dead_letter_quiet_period: must not be negative",
+				`watchdog.webhook.url: "ftp://example.com" must start with http:// or https://`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := Validate(cfg)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if len(validationErr.Errors) != len(tt.want) {
+				t.Fatalf("Validate() got %d errors, want %d:\n%v", len(validationErr.Errors), len(tt.want), err)
+			}
+			for idx, want := range tt.want {
+				if got := validationErr.Errors[idx].Error(); got != want {
+					t.Errorf("Validate() error[%d] = %q, want %q", idx, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrorFormat(t *testing.T) {
+	v := &Validator{}
+	v.Add("napcat.url", "is required")
+	v.AddError("napcat.group_id", nil)
+	v.Join(errors.Join(
+		&ValidationError{Errors: []error{&FieldError{Path: "mute.schedules[0].start", Err: errors.New("bad")}}},
+		errors.New("plain"),
+	))
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "collected errors",
+			err:  v.Err(),
+			want: "config has 3 errors:\n  napcat.url: is required\n  mute.schedules[0].start: bad\n  plain",
+		},
+		{
+			name: "single error",
+			err:  &ValidationError{Errors: []error{&FieldError{Path: "napcat.url", Err: errors.New("is required")}}},
+			want: "config has 1 error:\n  napcat.url: is required",
+		},
+		{
+			name: "field error",
+			err:  &FieldError{Path: "tracing.sample_ratio", Err: errors.New("must be between 0 and 1")},
+			want: "tracing.sample_ratio: must be between 0 and 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := (&Validator{}).Err(); err != nil {
+		t.Errorf("empty Validator.Err() = %v, want nil", err)
+	}
+}
@@ -0,0 +1,34 @@
+package api_token_enum
+
+// Scope 令牌可访问的接口范围
+type Scope string
+
+func (r Scope) String() string {
+	return string(r)
+}
+
+const (
+	// ScopeSourceEO EdgeOne webhook
+	ScopeSourceEO Scope = "source:eo"
+	// ScopeSend 发送消息接口
+	ScopeSend Scope = "send"
+	// ScopeAdmin 管理接口：消息查询与操作、静默规则、定时消息、令牌管理
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes 所有可用的范围
+var Scopes = []Scope{
+	ScopeSourceEO,
+	ScopeSend,
+	ScopeAdmin,
+}
+
+// ParseScope 根据名称解析范围
+func ParseScope(name string) (Scope, bool) {
+	for _, scope := range Scopes {
+		if scope.String() == name {
+			return scope, true
+		}
+	}
+	return "", false
+}
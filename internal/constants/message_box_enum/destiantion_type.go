@@ -1,5 +1,7 @@
 package message_box_enum
 
+import "slices"
+
 type DestinationType int32
 
 func (r DestinationType) Val() int32 {
@@ -11,3 +13,34 @@ const (
 	DestinationQQGroup DestinationType = iota + 1
 )
 
+var destinationNames = map[DestinationType]string{
+	DestinationQQGroup: "qq-group",
+}
+
+// String 返回目的地名称，用于配置和日志
+func (r DestinationType) String() string {
+	if name, ok := destinationNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// DestinationTypes 返回全部目的地类型，按类型值排序
+func DestinationTypes() []DestinationType {
+	types := make([]DestinationType, 0, len(destinationNames))
+	for t := range destinationNames {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// ParseDestinationType 根据目的地名称解析目的地类型
+func ParseDestinationType(name string) (DestinationType, bool) {
+	for t, n := range destinationNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
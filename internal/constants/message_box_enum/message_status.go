@@ -11,4 +11,42 @@ const (
 	Pending StatusType = iota + 1
 	// Sent 发送成功
 	Sent
+	// Muted 免打扰期间暂存，结束后合并为摘要发送
+	Muted
+	// Silenced 命中静默规则，不再发送
+	Silenced
+	// Expired 超过有效期仍未发送成功，不再重试
+	Expired
+	// Cancelled 人工取消，不再发送
+	Cancelled
+	// DeadLetter 发送失败次数达到上限，不再重试
+	DeadLetter
 )
+
+var statusNames = map[StatusType]string{
+	Pending:    "pending",
+	Sent:       "sent",
+	Muted:      "muted",
+	Silenced:   "silenced",
+	Expired:    "expired",
+	Cancelled:  "cancelled",
+	DeadLetter: "dead_letter",
+}
+
+// String 返回状态名称，用于接口参数和日志
+func (r StatusType) String() string {
+	if name, ok := statusNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseStatusType 根据名称解析状态
+func ParseStatusType(name string) (StatusType, bool) {
+	for t, n := range statusNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
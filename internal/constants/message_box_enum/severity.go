@@ -0,0 +1,40 @@
+package message_box_enum
+
+type Severity int32
+
+func (r Severity) Val() int32 {
+	return int32(r)
+}
+
+const (
+	// SeverityInfo 普通通知
+	SeverityInfo Severity = iota + 1
+	// SeverityWarning 警告
+	SeverityWarning
+	// SeverityCritical 严重，需要立即关注
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+// String 返回严重级别名称，用于配置和日志
+func (r Severity) String() string {
+	if name, ok := severityNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseSeverity 根据名称解析严重级别
+func ParseSeverity(name string) (Severity, bool) {
+	for s, n := range severityNames {
+		if n == name {
+			return s, true
+		}
+	}
+	return 0, false
+}
@@ -9,4 +9,35 @@ func (r SourceType) Val() int32 {
 const (
 	// SourceTypeEO EdgeOne
 	SourceTypeEO SourceType = iota + 1
+	// SourceTypeDigest 免打扰结束后的消息摘要
+	SourceTypeDigest
+	// SourceTypeSchedule 定时消息
+	SourceTypeSchedule
+	// SourceTypeCLI 命令行发送
+	SourceTypeCLI
 )
+
+var sourceNames = map[SourceType]string{
+	SourceTypeEO:       "eo",
+	SourceTypeDigest:   "digest",
+	SourceTypeSchedule: "schedule",
+	SourceTypeCLI:      "cli",
+}
+
+// String 返回来源名称，用于配置和日志
+func (r SourceType) String() string {
+	if name, ok := sourceNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseSourceType 根据来源名称解析来源类型
+func ParseSourceType(name string) (SourceType, bool) {
+	for t, n := range sourceNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,40 @@
+package scheduled_message_enum
+
+type StatusType int32
+
+func (r StatusType) Val() int32 {
+	return int32(r)
+}
+
+const (
+	// Active 等待触发
+	Active StatusType = iota + 1
+	// Finished 一次性消息已触发
+	Finished
+	// Cancelled 已取消
+	Cancelled
+)
+
+var statusNames = map[StatusType]string{
+	Active:    "active",
+	Finished:  "finished",
+	Cancelled: "cancelled",
+}
+
+// String 返回状态名称，用于接口参数和日志
+func (r StatusType) String() string {
+	if name, ok := statusNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseStatusType 根据名称解析状态
+func ParseStatusType(name string) (StatusType, bool) {
+	for t, n := range statusNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
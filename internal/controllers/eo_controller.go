@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"errors"
+	"log/slog"
 	"message-pocket/internal/config"
 	"message-pocket/internal/define/dtos"
 	"message-pocket/internal/services"
@@ -43,11 +45,16 @@ func (c *EOController) EOWebhookEvent(e *core.RequestEvent) error {
 	if err := e.BindBody(&req); err != nil {
 		return err
 	}
-	e.App.Logger().InfoContext(ctx, "Received EO event", "request", req)
+	// 使用默认 logger 以经过脱敏 handler
+	slog.InfoContext(ctx, "Received EO event", "request", req)
 
 	// 调用服务处理事件
 	if err := c.eoService.EOWebhookEventHandle(ctx, &req); err != nil {
-		e.App.Logger().ErrorContext(ctx, "Failed to process EO event", "err", err)
+		if errors.Is(err, services.ErrWebhookTimestampSkewed) {
+			slog.WarnContext(ctx, "Rejected EO event with skewed timestamp", "err", err)
+			return e.JSON(400, utils.NewJsonResponseWithoutData(400, "Event timestamp is outside the tolerance window"))
+		}
+		slog.ErrorContext(ctx, "Failed to process EO event", "err", err)
 		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to process event"))
 	}
 
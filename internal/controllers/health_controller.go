@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"log/slog"
+	"message-pocket/internal/services"
+	"message-pocket/internal/utils"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+// HealthController 就绪检查和依赖状态控制器，存活检查使用 PocketBase 自带的 /api/health
+type HealthController struct {
+	healthService *services.HealthService
+}
+
+// NewHealthController 创建健康检查控制器实例
+func NewHealthController(healthService *services.HealthService) *HealthController {
+	return &HealthController{
+		healthService: healthService,
+	}
+}
+
+func ProvideHealthController(i do.Injector) (*HealthController, error) {
+	healthService := do.MustInvoke[*services.HealthService](i)
+	return NewHealthController(healthService), nil
+}
+
+// Ready 就绪检查，不需要令牌，只返回 200 或 503，未通过的检查项记录在日志中
+func (c *HealthController) Ready(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	report := c.healthService.Ready(ctx)
+	if !report.Ready {
+		for _, check := range report.Checks {
+			if !check.OK {
+				slog.WarnContext(ctx, "Readiness check failed", "check", check.Name, "err", check.Error)
+			}
+		}
+		return e.JSON(503, utils.NewJsonResponseWithoutData(503, "Not ready"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponseWithoutData(0, "Success"))
+}
+
+// Status 目的地和 NapCat 状态，需要 admin 令牌，NapCat 不在线时返回 503，便于拨测按状态码告警
+func (c *HealthController) Status(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	report, err := c.healthService.Status(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get status", "err", err)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to get status"))
+	}
+	if !report.Healthy {
+		return e.JSON(503, utils.NewJsonResponse(503, "Unhealthy", report))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", report))
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/define/dtos"
+	"message-pocket/internal/middlewares"
+	"message-pocket/internal/services"
+	"message-pocket/internal/utils"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 200
+)
+
+// defaultOperator 无法确定令牌时记录的操作人
+const defaultOperator = "api"
+
+// MessageController 消息查询与人工操作控制器
+type MessageController struct {
+	messageBoxService       *services.MessageBoxService
+	messageOperationService *services.MessageOperationService
+}
+
+// NewMessageController 创建消息控制器实例
+func NewMessageController(
+	messageBoxService *services.MessageBoxService,
+	messageOperationService *services.MessageOperationService,
+) *MessageController {
+	return &MessageController{
+		messageBoxService:       messageBoxService,
+		messageOperationService: messageOperationService,
+	}
+}
+
+func ProvideMessageController(i do.Injector) (*MessageController, error) {
+	messageBoxService := do.MustInvoke[*services.MessageBoxService](i)
+	messageOperationService := do.MustInvoke[*services.MessageOperationService](i)
+	return NewMessageController(messageBoxService, messageOperationService), nil
+}
+
+// ListMessages 分页查询消息
+func (c *MessageController) ListMessages(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	req, err := parseListMessagesRequest(e.Request.URL.Query())
+	if err != nil {
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	}
+
+	messages, total, err := c.messageBoxService.ListMessages(ctx, req)
+	if errors.Is(err, services.ErrInvalidArgument) {
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list messages", "err", err)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to list messages"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", dtos.ListMessagesResponse{
+		Items:   messages,
+		Page:    req.Page,
+		PerPage: req.PerPage,
+		Total:   total,
+	}))
+}
+
+// GetMessage 查询消息详情
+func (c *MessageController) GetMessage(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	id := e.Request.PathValue("id")
+
+	message, err := c.messageBoxService.GetMessage(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get message", "err", err, "message_id", id)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to get message"))
+	}
+	if message == nil {
+		return e.JSON(404, utils.NewJsonResponseWithoutData(404, "message not found"))
+	}
+
+	attempts, err := c.messageBoxService.ListAttempts(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list delivery attempts", "err", err, "message_id", id)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to get message"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", dtos.MessageDetailResponse{
+		MessageBoxModel:  message,
+		DeliveryAttempts: attempts,
+	}))
+}
+
+// ResendMessage 重发单条消息，可选发送到其他目的地
+func (c *MessageController) ResendMessage(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	id := e.Request.PathValue("id")
+
+	var req dtos.ResendMessageRequest
+	if err := e.BindBody(&req); err != nil {
+		return err
+	}
+
+	in := services.ResendMessageRequest{
+		MessageID:  id,
+		Target:     req.Target,
+		OperatedBy: operator(e),
+		DryRun:     req.DryRun,
+	}
+	if req.Destination != "" {
+		destination, ok := message_box_enum.ParseDestinationType(req.Destination)
+		if !ok {
+			return e.JSON(400, utils.NewJsonResponseWithoutData(400, "invalid destination"))
+		}
+		in.DestinationType = destination
+	}
+
+	result, err := c.messageOperationService.Resend(ctx, in)
+	return c.operationResponse(e, result, err)
+}
+
+// CancelMessage 取消发送中或暂存的消息
+func (c *MessageController) CancelMessage(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	id := e.Request.PathValue("id")
+
+	var req dtos.MessageOperationRequest
+	if err := e.BindBody(&req); err != nil {
+		return err
+	}
+
+	result, err := c.messageOperationService.Cancel(ctx, services.CancelMessageRequest{
+		MessageID:  id,
+		OperatedBy: operator(e),
+		DryRun:     req.DryRun,
+	})
+	return c.operationResponse(e, result, err)
+}
+
+// BulkRetryMessages 按查询参数过滤并批量重试消息，过滤条件与 ListMessages 相同
+func (c *MessageController) BulkRetryMessages(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	filter, err := parseListMessagesRequest(e.Request.URL.Query())
+	if err != nil {
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	}
+
+	var req dtos.MessageOperationRequest
+	if err := e.BindBody(&req); err != nil {
+		return err
+	}
+
+	result, err := c.messageOperationService.BulkRetry(ctx, services.BulkRetryRequest{
+		Filter:     filter,
+		OperatedBy: operator(e),
+		DryRun:     req.DryRun,
+	})
+	return c.operationResponse(e, result, err)
+}
+
+func (c *MessageController) operationResponse(e *core.RequestEvent, result *services.OperationResult, err error) error {
+	ctx := e.Request.Context()
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		return e.JSON(404, utils.NewJsonResponseWithoutData(404, "message not found"))
+	case errors.Is(err, services.ErrInvalidArgument):
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to operate message", "err", err)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to operate message"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", result))
+}
+
+// operator 操作人为认证通过的令牌名称，不从请求体读取，避免调用方冒用他人
+func operator(e *core.RequestEvent) string {
+	if token := middlewares.GetAPIToken(e); token != nil && token.Name != "" {
+		return token.Name
+	}
+	return defaultOperator
+}
+
+// parseListMessagesRequest 解析查询参数，枚举参数使用名称，时间参数支持 RFC3339 或 Unix 秒
+func parseListMessagesRequest(query url.Values) (services.ListMessagesRequest, error) {
+	req := services.ListMessagesRequest{
+		BizID:   query.Get("biz_id"),
+		TraceID: query.Get("trace_id"),
+		Keyword: query.Get("q"),
+		Sort:    query.Get("sort"),
+		Page:    1,
+		PerPage: defaultPerPage,
+	}
+
+	if names := query.Get("status"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			status, ok := message_box_enum.ParseStatusType(name)
+			if !ok {
+				return req, fmt.Errorf("invalid status: %s", name)
+			}
+			req.Statuses = append(req.Statuses, status)
+		}
+	}
+	if name := query.Get("source"); name != "" {
+		source, ok := message_box_enum.ParseSourceType(name)
+		if !ok {
+			return req, fmt.Errorf("invalid source: %s", name)
+		}
+		req.SourceType = source
+	}
+	if name := query.Get("destination"); name != "" {
+		destination, ok := message_box_enum.ParseDestinationType(name)
+		if !ok {
+			return req, fmt.Errorf("invalid destination: %s", name)
+		}
+		req.DestinationType = destination
+	}
+
+	var err error
+	if req.CreatedFrom, err = parseQueryTime(query.Get("from")); err != nil {
+		return req, fmt.Errorf("invalid from: %w", err)
+	}
+	if req.CreatedTo, err = parseQueryTime(query.Get("to")); err != nil {
+		return req, fmt.Errorf("invalid to: %w", err)
+	}
+
+	if page := query.Get("page"); page != "" {
+		if req.Page, err = strconv.Atoi(page); err != nil || req.Page < 1 {
+			return req, fmt.Errorf("invalid page: %s", page)
+		}
+	}
+	if perPage := query.Get("per_page"); perPage != "" {
+		if req.PerPage, err = strconv.Atoi(perPage); err != nil || req.PerPage < 1 {
+			return req, fmt.Errorf("invalid per_page: %s", perPage)
+		}
+		req.PerPage = min(req.PerPage, maxPerPage)
+	}
+
+	return req, nil
+}
+
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/constants/scheduled_message_enum"
+	"message-pocket/internal/define/dtos"
+	"message-pocket/internal/services"
+	"message-pocket/internal/utils"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+// ScheduleController 定时消息控制器
+type ScheduleController struct {
+	scheduleService *services.ScheduleService
+}
+
+// NewScheduleController 创建定时消息控制器实例
+func NewScheduleController(scheduleService *services.ScheduleService) *ScheduleController {
+	return &ScheduleController{
+		scheduleService: scheduleService,
+	}
+}
+
+func ProvideScheduleController(i do.Injector) (*ScheduleController, error) {
+	scheduleService := do.MustInvoke[*services.ScheduleService](i)
+	return NewScheduleController(scheduleService), nil
+}
+
+// CreateSchedule 创建定时消息
+func (c *ScheduleController) CreateSchedule(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	var req dtos.CreateScheduleRequest
+	if err := e.BindBody(&req); err != nil {
+		return err
+	}
+
+	in := services.CreateScheduleRequest{
+		Message:      req.Message,
+		Destinations: req.Destinations,
+		CronExpr:     req.Cron,
+		Timezone:     req.Timezone,
+		CreatedBy:    operator(e),
+	}
+	if req.Severity != "" {
+		severity, ok := message_box_enum.ParseSeverity(req.Severity)
+		if !ok {
+			return e.JSON(400, utils.NewJsonResponseWithoutData(400, "invalid severity"))
+		}
+		in.Severity = severity
+	}
+	if req.SendAt != "" {
+		sendAt, err := time.Parse(time.RFC3339, req.SendAt)
+		if err != nil {
+			return e.JSON(400, utils.NewJsonResponseWithoutData(400, "invalid send_at"))
+		}
+		in.SendAt = sendAt
+	}
+	if req.ExpireAfter != "" {
+		expireAfter, err := time.ParseDuration(req.ExpireAfter)
+		if err != nil {
+			return e.JSON(400, utils.NewJsonResponseWithoutData(400, "invalid expire_after"))
+		}
+		in.ExpireAfter = expireAfter
+	}
+
+	schedule, err := c.scheduleService.CreateSchedule(ctx, in)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to create schedule", "err", err)
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", schedule))
+}
+
+// ListSchedules 查询定时消息，status 默认为 active
+func (c *ScheduleController) ListSchedules(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	status := scheduled_message_enum.Active
+	if name := e.Request.URL.Query().Get("status"); name != "" {
+		parsed, ok := scheduled_message_enum.ParseStatusType(name)
+		if !ok {
+			return e.JSON(400, utils.NewJsonResponseWithoutData(400, "invalid status"))
+		}
+		status = parsed
+	}
+
+	schedules, err := c.scheduleService.ListSchedules(ctx, status)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list schedules", "err", err)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to list schedules"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", schedules))
+}
+
+// CancelSchedule 取消定时消息
+func (c *ScheduleController) CancelSchedule(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	id, err := strconv.ParseInt(e.Request.PathValue("id"), 10, 32)
+	if err != nil {
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, "invalid schedule id"))
+	}
+
+	err = c.scheduleService.CancelSchedule(ctx, int32(id))
+	if errors.Is(err, services.ErrScheduleNotFound) {
+		return e.JSON(404, utils.NewJsonResponseWithoutData(404, err.Error()))
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to cancel schedule", "err", err, "schedule_id", id)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to cancel schedule"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponseWithoutData(0, "Success"))
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/define/dtos"
+	"message-pocket/internal/services"
+	"message-pocket/internal/utils"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+// SilenceController 静默规则控制器
+type SilenceController struct {
+	muteService *services.MuteService
+}
+
+// NewSilenceController 创建静默规则控制器实例
+func NewSilenceController(muteService *services.MuteService) *SilenceController {
+	return &SilenceController{
+		muteService: muteService,
+	}
+}
+
+func ProvideSilenceController(i do.Injector) (*SilenceController, error) {
+	muteService := do.MustInvoke[*services.MuteService](i)
+	return NewSilenceController(muteService), nil
+}
+
+// CreateSilence 创建静默规则
+func (c *SilenceController) CreateSilence(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	var req dtos.CreateSilenceRequest
+	if err := e.BindBody(&req); err != nil {
+		return err
+	}
+
+	startsAt, endsAt, err := parseSilencePeriod(req)
+	if err != nil {
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	}
+
+	silence, err := c.muteService.CreateSilence(ctx, services.CreateSilenceRequest{
+		Matchers:  req.Matchers,
+		Comment:   req.Comment,
+		CreatedBy: operator(e),
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to create silence", "err", err)
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", silence))
+}
+
+// ListSilences 查询生效中以及尚未开始的静默规则
+func (c *SilenceController) ListSilences(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	silences, err := c.muteService.ListSilences(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list silences", "err", err)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to list silences"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", silences))
+}
+
+// ExpireSilence 使静默规则立即失效
+func (c *SilenceController) ExpireSilence(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	id, err := strconv.ParseInt(e.Request.PathValue("id"), 10, 32)
+	if err != nil {
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, "invalid silence id"))
+	}
+
+	err = c.muteService.ExpireSilence(ctx, int32(id))
+	if errors.Is(err, services.ErrSilenceNotFound) {
+		return e.JSON(404, utils.NewJsonResponseWithoutData(404, err.Error()))
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to expire silence", "err", err, "silence_id", id)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to expire silence"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponseWithoutData(0, "Success"))
+}
+
+// parseSilencePeriod 解析静默规则的生效时间段
+func parseSilencePeriod(req dtos.CreateSilenceRequest) (time.Time, time.Time, error) {
+	startsAt := time.Now()
+	if req.StartsAt != "" {
+		t, err := time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid starts_at: %w", err)
+		}
+		startsAt = t
+	}
+
+	switch {
+	case req.EndsAt != "":
+		endsAt, err := time.Parse(time.RFC3339, req.EndsAt)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid ends_at: %w", err)
+		}
+		return startsAt, endsAt, nil
+	case req.Duration != "":
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid duration: %w", err)
+		}
+		return startsAt, startsAt.Add(duration), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("ends_at or duration is required")
+	}
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/define/dtos"
+	"message-pocket/internal/services"
+	"message-pocket/internal/utils"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+// TokenController API 令牌管理控制器
+type TokenController struct {
+	tokenService *services.TokenService
+}
+
+// NewTokenController 创建令牌控制器实例
+func NewTokenController(tokenService *services.TokenService) *TokenController {
+	return &TokenController{
+		tokenService: tokenService,
+	}
+}
+
+func ProvideTokenController(i do.Injector) (*TokenController, error) {
+	tokenService := do.MustInvoke[*services.TokenService](i)
+	return NewTokenController(tokenService), nil
+}
+
+// CreateToken 创建令牌，明文只在响应中返回一次
+func (c *TokenController) CreateToken(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	var req dtos.CreateTokenRequest
+	if err := e.BindBody(&req); err != nil {
+		return err
+	}
+
+	expiresAt, err := parseTokenExpiry(req)
+	if err != nil {
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	}
+
+	token, err := c.tokenService.CreateToken(ctx, services.CreateTokenRequest{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+		CreatedBy: operator(e),
+	})
+	return c.tokenResponse(e, token, err)
+}
+
+// ListTokens 查询全部令牌
+func (c *TokenController) ListTokens(e *core.RequestEvent) error {
+	ctx := e.Request.Context()
+
+	tokens, err := c.tokenService.ListTokens(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list tokens", "err", err)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Failed to list tokens"))
+	}
+
+	return e.JSON(200, utils.NewJsonResponse(0, "Success", tokens))
+}
+
+// RotateToken 为令牌生成新的明文，旧明文立即失效
+func (c *TokenController) RotateToken(e *core.RequestEvent) error {
+	token, err := c.tokenService.RotateToken(e.Request.Context(), e.Request.PathValue("id"))
+	return c.tokenResponse(e, token, err)
+}
+
+// RevokeToken 吊销令牌
+func (c *TokenController) RevokeToken(e *core.RequestEvent) error {
+	token, err := c.tokenService.RevokeToken(e.Request.Context(), e.Request.PathValue("id"))
+	return c.tokenResponse(e, token, err)
+}
+
+func (c *TokenController) tokenResponse(e *core.RequestEvent, token any, err error) error {
+	switch {
+	case err == nil:
+		return e.JSON(200, utils.NewJsonResponse(0, "Success", token))
+	case errors.Is(err, services.ErrTokenNotFound):
+		return e.JSON(404, utils.NewJsonResponseWithoutData(404, err.Error()))
+	case errors.Is(err, services.ErrInvalidArgument):
+		return e.JSON(400, utils.NewJsonResponseWithoutData(400, err.Error()))
+	default:
+		slog.ErrorContext(e.Request.Context(), "Token operation failed", "err", err)
+		return e.JSON(500, utils.NewJsonResponseWithoutData(500, "Token operation failed"))
+	}
+}
+
+// parseTokenExpiry 解析令牌过期时间，零值表示不过期
+func parseTokenExpiry(req dtos.CreateTokenRequest) (time.Time, error) {
+	switch {
+	case req.ExpiresAt != "":
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expires_at: %w", err)
+		}
+		return expiresAt, nil
+	case req.ExpiresIn != "":
+		duration, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expires_in: %w", err)
+		}
+		return time.Now().Add(duration), nil
+	default:
+		return time.Time{}, nil
+	}
+}
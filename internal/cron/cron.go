@@ -3,6 +3,7 @@ package cron
 import (
 	"context"
 	"log/slog"
+	"message-pocket/internal/tracing"
 
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/samber/do/v2"
@@ -19,11 +20,13 @@ var jobs = make([]*Job, 0)
 func Init(app core.App, i do.Injector) {
 	for _, job := range jobs {
 		err := app.Cron().Add(job.Name, job.CronExpr, func() {
-			ctx := context.Background()
+			// 每次执行开始一个新的 trace
+			ctx, span := tracing.Start(context.Background(), "cron "+job.Name)
 			err := job.handle(ctx, i)
 			if err != nil {
 				slog.ErrorContext(ctx, "job run fail", "name", job.Name, "err", err)
 			}
+			tracing.End(span, err)
 		})
 		if err != nil {
 			panic(err)
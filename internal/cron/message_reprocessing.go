@@ -10,7 +10,7 @@ import (
 func init() {
 	jobs = append(jobs, &Job{
 		Name:     "message_reprocessing",
-		CronExpr: "",
+		CronExpr: "* * * * *",
 		handle:   MessageReProcessing,
 	})
 }
@@ -0,0 +1,21 @@
+package cron
+
+import (
+	"context"
+	"message-pocket/internal/services"
+
+	"github.com/samber/do/v2"
+)
+
+func init() {
+	jobs = append(jobs, &Job{
+		Name:     "mute_digest",
+		CronExpr: "* * * * *",
+		handle:   MuteDigest,
+	})
+}
+
+func MuteDigest(ctx context.Context, i do.Injector) error {
+	messageBoxService := do.MustInvoke[*services.MessageBoxService](i)
+	return messageBoxService.SendMuteDigest(ctx)
+}
@@ -0,0 +1,21 @@
+package cron
+
+import (
+	"context"
+	"message-pocket/internal/services"
+
+	"github.com/samber/do/v2"
+)
+
+func init() {
+	jobs = append(jobs, &Job{
+		Name:     "scheduled_message",
+		CronExpr: "* * * * *",
+		handle:   ScheduledMessage,
+	})
+}
+
+func ScheduledMessage(ctx context.Context, i do.Injector) error {
+	scheduleService := do.MustInvoke[*services.ScheduleService](i)
+	return scheduleService.FireDue(ctx)
+}
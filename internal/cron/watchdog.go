@@ -0,0 +1,24 @@
+package cron
+
+import (
+	"context"
+	"message-pocket/internal/services"
+
+	"github.com/samber/do/v2"
+)
+
+func init() {
+	jobs = append(jobs, &Job{
+		Name:     "watchdog",
+		CronExpr: "* * * * *",
+		handle:   Watchdog,
+	})
+}
+
+func Watchdog(ctx context.Context, i do.Injector) error {
+	watchdogService, err := do.Invoke[*services.WatchdogService](i)
+	if err != nil {
+		return err
+	}
+	return watchdogService.Check(ctx)
+}
@@ -0,0 +1,21 @@
+package cron
+
+import (
+	"context"
+	"message-pocket/internal/services"
+
+	"github.com/samber/do/v2"
+)
+
+func init() {
+	jobs = append(jobs, &Job{
+		Name:     "webhook_delivery_cleanup",
+		CronExpr: "0 * * * *",
+		handle:   WebhookDeliveryCleanup,
+	})
+}
+
+func WebhookDeliveryCleanup(ctx context.Context, i do.Injector) error {
+	webhookDeliveryService := do.MustInvoke[*services.WebhookDeliveryService](i)
+	return webhookDeliveryService.Cleanup(ctx)
+}
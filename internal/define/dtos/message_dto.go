@@ -0,0 +1,31 @@
+package dtos
+
+import "message-pocket/internal/define/model"
+
+// ListMessagesResponse 分页查询消息响应
+type ListMessagesResponse struct {
+	Items   []*model.MessageBoxModel `json:"items"`
+	Page    int                      `json:"page"`
+	PerPage int                      `json:"per_page"`
+	Total   int64                    `json:"total"`
+}
+
+// MessageDetailResponse 消息详情响应，附带按时间顺序的发送历史
+type MessageDetailResponse struct {
+	*model.MessageBoxModel
+	DeliveryAttempts []*model.MessageAttemptModel `json:"delivery_attempts"`
+}
+
+// ResendMessageRequest 重发消息请求
+type ResendMessageRequest struct {
+	// Destination 目的地名称，为空表示原目的地
+	Destination string `json:"destination"`
+	// Target 发送目标，如群号，为空表示目的地的默认目标
+	Target string `json:"target"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// MessageOperationRequest 取消、批量重试消息请求
+type MessageOperationRequest struct {
+	DryRun bool `json:"dry_run"`
+}
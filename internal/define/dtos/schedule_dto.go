@@ -0,0 +1,18 @@
+package dtos
+
+// CreateScheduleRequest 创建定时消息请求，send_at 与 cron 二选一
+type CreateScheduleRequest struct {
+	Message string `json:"message"`
+	// Destinations 目的地名称列表，如 qq-group
+	Destinations []string `json:"destinations"`
+	// Severity info、warning、critical，为空时为 info
+	Severity string `json:"severity"`
+	// SendAt RFC3339 格式的发送时间
+	SendAt string `json:"send_at"`
+	// Cron 周期消息的 cron 表达式
+	Cron string `json:"cron"`
+	// Timezone 计算 cron 表达式使用的 IANA 时区，为空使用 UTC
+	Timezone string `json:"timezone"`
+	// ExpireAfter 触发后超过该时长仍未发送成功则放弃，如 30m
+	ExpireAfter string `json:"expire_after"`
+}
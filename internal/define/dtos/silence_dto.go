@@ -0,0 +1,15 @@
+package dtos
+
+import "message-pocket/internal/define/model"
+
+// CreateSilenceRequest 创建静默规则请求
+type CreateSilenceRequest struct {
+	Matchers []model.SilenceMatcher `json:"matchers"`
+	Comment  string                 `json:"comment"`
+	// StartsAt RFC3339 格式，为空表示立即生效
+	StartsAt string `json:"starts_at"`
+	// EndsAt RFC3339 格式，与 Duration 二选一
+	EndsAt string `json:"ends_at"`
+	// Duration 持续时长，如 2h、30m
+	Duration string `json:"duration"`
+}
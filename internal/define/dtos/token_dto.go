@@ -0,0 +1,12 @@
+package dtos
+
+// CreateTokenRequest 创建令牌请求
+type CreateTokenRequest struct {
+	Name string `json:"name"`
+	// Scopes 令牌可访问的范围：source:eo、send、admin
+	Scopes []string `json:"scopes"`
+	// ExpiresAt RFC3339 格式，与 ExpiresIn 二选一，都为空表示不过期
+	ExpiresAt string `json:"expires_at"`
+	// ExpiresIn 有效时长，如 720h
+	ExpiresIn string `json:"expires_in"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"message-pocket/internal/constants/api_token_enum"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+type APITokenModel struct {
+	ID   string `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	// TokenPrefix 令牌明文的前几位，用于辨认令牌
+	TokenPrefix string `json:"token_prefix" db:"token_prefix"`
+	// TokenHash 令牌明文的 SHA-256，明文只在创建和轮换时返回一次
+	TokenHash string                  `json:"-" db:"token_hash"`
+	Scopes    types.JSONArray[string] `json:"scopes" db:"scopes"`
+	ExpiresAt int64                   `json:"expires_at" db:"expires_at"`
+	// LastUsedAt 最近一次认证通过的时间，按分钟粒度更新
+	LastUsedAt int64  `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  int64  `json:"revoked_at" db:"revoked_at"`
+	CreatedBy  string `json:"created_by" db:"created_by"`
+	CreatedAt  int64  `json:"created_at" db:"created_at"`
+}
+
+// HasScope 判断令牌是否拥有指定范围
+func (m *APITokenModel) HasScope(scope api_token_enum.Scope) bool {
+	return slices.Contains(m.Scopes, scope.String())
+}
@@ -0,0 +1,21 @@
+package model
+
+// EODeploymentModel EdgeOne 部署状态，按项目和部署 ID 关联同一次部署的各个事件
+type EODeploymentModel struct {
+	ID            int32  `json:"id" db:"id"`
+	ProjectID     string `json:"project_id" db:"project_id"`
+	DeploymentID  string `json:"deployment_id" db:"deployment_id"`
+	ProjectName   string `json:"project_name" db:"project_name"`
+	RepoBranch    string `json:"repo_branch" db:"repo_branch"`
+	LastEventType string `json:"last_event_type" db:"last_event_type"`
+	// Result 部署结果：succeeded、failed、cancelled，未结束时为空
+	Result string `json:"result" db:"result"`
+	// FailStreak 截至本次部署，同一分支连续失败的次数
+	FailStreak      int32 `json:"fail_streak" db:"fail_streak"`
+	CreatedAt       int64 `json:"created_at" db:"created_at"`
+	BuildStartedAt  int64 `json:"build_started_at" db:"build_started_at"`
+	BuildFinishedAt int64 `json:"build_finished_at" db:"build_finished_at"`
+	DeployStartedAt int64 `json:"deploy_started_at" db:"deploy_started_at"`
+	FinishedAt      int64 `json:"finished_at" db:"finished_at"`
+	UpdatedAt       int64 `json:"updated_at" db:"updated_at"`
+}
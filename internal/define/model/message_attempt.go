@@ -0,0 +1,24 @@
+package model
+
+import "message-pocket/internal/constants/message_box_enum"
+
+// MessageAttemptModel 一次调用目的地发送消息的记录
+type MessageAttemptModel struct {
+	ID              string                           `json:"id" db:"id"`
+	MessageID       string                           `json:"message_id" db:"message_id"`
+	DestinationType message_box_enum.DestinationType `json:"destination_type" db:"destination_type"`
+	// Target 实际发送的目标，如群号
+	Target string `json:"target" db:"target"`
+	// Trigger 触发方式：inline、retry、manual
+	Trigger    string `json:"trigger" db:"trigger"`
+	DurationMS int64  `json:"duration_ms" db:"duration_ms"`
+	// HTTPStatus 目的地接口的 HTTP 状态码，未收到响应时为 0
+	HTTPStatus int `json:"http_status" db:"http_status"`
+	// Retcode NapCat 返回的 retcode
+	Retcode int `json:"retcode" db:"retcode"`
+	// Error 为空表示发送成功
+	Error string `json:"error" db:"error"`
+	// Bot 发送使用的机器人，如 NapCat 的地址
+	Bot       string `json:"bot" db:"bot"`
+	CreatedAt int64  `json:"created_at" db:"created_at"`
+}
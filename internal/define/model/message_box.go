@@ -1,15 +1,61 @@
 package model
 
-import "message-pocket/internal/constants/message_box_enum"
+import (
+	"message-pocket/internal/constants/message_box_enum"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+)
 
 type MessageBoxModel struct {
-	ID              int32                            `json:"id" db:"id"`
+	ID              string                           `json:"id" db:"id"`
 	BizID           string                           `json:"biz_id" db:"biz_id"`
 	Status          int32                            `json:"status" db:"status"`
 	Message         string                           `json:"message" db:"message"`
-	SourceRequest   string                           `json:"source_request" db:"source_request"`
+	SourceRequest   string                           `json:"source_request,omitempty" db:"source_request"`
 	SourceType      message_box_enum.SourceType      `json:"source_type" db:"source_type"`
 	DestinationType message_box_enum.DestinationType `json:"destination_type" db:"destination_type"`
+	Severity        message_box_enum.Severity        `json:"severity" db:"severity"`
+	ExpiresAt       int64                            `json:"expires_at" db:"expires_at"`
 	CreatedAt       string                           `json:"created_at" db:"created_at"`
-	LastedSentAt    string                           `json:"lasted_sent_at" db:"lasted_sent_at"`
+	LastSentAt      int64                            `json:"last_sent_at" db:"last_sent_at"`
+	LastError       string                           `json:"last_error" db:"last_error"`
+	// Attempts 实际调用目的地发送的次数，被限流延后的不计入
+	Attempts int32 `json:"attempts" db:"attempts"`
+	// Receipt 最近一次发送成功的回执，见 DeliveryReceipt
+	Receipt types.JSONRaw `json:"receipt" db:"receipt"`
+	// Target 指定的发送目标，如群号，为空时使用目的地的默认配置
+	Target string `json:"target" db:"target"`
+	// Operation 最近一次人工操作：resend、cancel、retry
+	Operation  string `json:"operation" db:"operation"`
+	OperatedBy string `json:"operated_by" db:"operated_by"`
+	OperatedAt int64  `json:"operated_at" db:"operated_at"`
+	// KeyID 加密 message 和 source_request 的主密钥 ID，为空表示明文
+	KeyID string `json:"key_id" db:"key_id"`
+	// DataKey 主密钥加密后的数据密钥
+	DataKey string `json:"-" db:"data_key"`
+	// TraceID 创建消息时的 trace，重试在同一 trace 中进行
+	TraceID string `json:"trace_id" db:"trace_id"`
+	// SpanID 创建消息时的 span，作为重试 span 的父 span
+	SpanID string `json:"span_id" db:"span_id"`
+	// TraceFlags 创建消息时的 trace flags，重试沿用原请求的采样决定
+	TraceFlags string `json:"trace_flags" db:"trace_flags"`
+	// IdempotencyKey 幂等键，同一来源只保存一条，为空表示不去重
+	IdempotencyKey string `json:"idempotency_key" db:"idempotency_key"`
+}
+
+// DeliveryReceipt 发送回执
+type DeliveryReceipt struct {
+	// Target 实际发送的目标，如群号
+	Target string `json:"target"`
+	// ExternalID 目的地返回的消息 ID，如 NapCat 的 message_id
+	ExternalID string `json:"external_id"`
+	// DigestID 免打扰暂存的消息合并到的摘要消息 ID，由摘要代为发送
+	DigestID string `json:"digest_id,omitempty"`
+}
+
+// MessageCountModel 按状态和目的地统计的消息数
+type MessageCountModel struct {
+	Status          message_box_enum.StatusType      `json:"status" db:"status"`
+	DestinationType message_box_enum.DestinationType `json:"destination_type" db:"destination_type"`
+	Count           int64                            `json:"count" db:"count"`
 }
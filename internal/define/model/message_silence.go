@@ -0,0 +1,20 @@
+package model
+
+import "github.com/pocketbase/pocketbase/tools/types"
+
+// SilenceMatcher 静默规则的标签匹配条件
+type SilenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"is_regex"`
+}
+
+type MessageSilenceModel struct {
+	ID        int32                           `json:"id" db:"id"`
+	Matchers  types.JSONArray[SilenceMatcher] `json:"matchers" db:"matchers"`
+	Comment   string                          `json:"comment" db:"comment"`
+	CreatedBy string                          `json:"created_by" db:"created_by"`
+	StartsAt  int64                           `json:"starts_at" db:"starts_at"`
+	EndsAt    int64                           `json:"ends_at" db:"ends_at"`
+	CreatedAt int64                           `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/constants/scheduled_message_enum"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+type ScheduledMessageModel struct {
+	ID      int32  `json:"id" db:"id"`
+	Message string `json:"message" db:"message"`
+	// Destinations 目的地名称列表，如 qq-group
+	Destinations types.JSONArray[string]   `json:"destinations" db:"destinations"`
+	Severity     message_box_enum.Severity `json:"severity" db:"severity"`
+	// CronExpr 周期消息的 cron 表达式，一次性消息为空
+	CronExpr string `json:"cron_expr" db:"cron_expr"`
+	// Timezone 计算 cron 表达式使用的 IANA 时区，为空使用 UTC
+	Timezone string `json:"timezone" db:"timezone"`
+	// ExpireAfter 触发后超过该秒数仍未发送成功则放弃，0 表示不过期
+	ExpireAfter int64                             `json:"expire_after" db:"expire_after"`
+	Status      scheduled_message_enum.StatusType `json:"status" db:"status"`
+	NextRunAt   int64                             `json:"next_run_at" db:"next_run_at"`
+	LastRunAt   int64                             `json:"last_run_at" db:"last_run_at"`
+	CreatedBy   string                            `json:"created_by" db:"created_by"`
+	CreatedAt   int64                             `json:"created_at" db:"created_at"`
+}
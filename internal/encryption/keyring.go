@@ -0,0 +1,227 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"message-pocket/internal/config"
+	"os"
+	"strings"
+
+	"github.com/samber/do/v2"
+)
+
+// ciphertextPrefix 密文字段的前缀，用于区分历史明文
+const ciphertextPrefix = "enc:v1:"
+
+// keySize AES-256 密钥长度
+const keySize = 32
+
+// ErrUnknownKey 数据使用的主密钥未配置
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring 信封加密：每行数据使用随机数据密钥 AES-GCM 加密，数据密钥再由主密钥加密后与密钥 ID 一起保存
+// 轮换主密钥只需重新加密数据密钥
+type Keyring struct {
+	activeKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+// NewKeyring 从配置加载主密钥，未配置 active_key 时不加密新数据，但仍可解密已有数据
+func NewKeyring(cfg config.EncryptionConfig) (*Keyring, error) {
+	keyring := &Keyring{
+		activeKeyID: cfg.ActiveKey,
+		keys:        make(map[string]cipher.AEAD, len(cfg.Keys)),
+	}
+
+	for idx, item := range cfg.Keys {
+		if item.ID == "" {
+			return nil, fmt.Errorf("encryption.keys[%d]: id is required", idx)
+		}
+		if _, ok := keyring.keys[item.ID]; ok {
+			return nil, fmt.Errorf("encryption.keys[%d]: duplicate id %q", idx, item.ID)
+		}
+		key, err := loadKey(item)
+		if err != nil {
+			return nil, fmt.Errorf("encryption.keys[%d]: %w", idx, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption.keys[%d]: %w", idx, err)
+		}
+		keyring.keys[item.ID] = aead
+	}
+
+	if cfg.ActiveKey != "" {
+		if _, ok := keyring.keys[cfg.ActiveKey]; !ok {
+			return nil, fmt.Errorf("encryption.active_key: key %q is not configured", cfg.ActiveKey)
+		}
+	}
+
+	return keyring, nil
+}
+
+func ProvideKeyring(i do.Injector) (*Keyring, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	return NewKeyring(cfg.Encryption)
+}
+
+// Enabled 是否加密新数据
+func (k *Keyring) Enabled() bool {
+	return k.activeKeyID != ""
+}
+
+// ActiveKeyID 当前用于加密的主密钥 ID
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeKeyID
+}
+
+// Seal 生成数据密钥加密多个字段，返回主密钥 ID、加密后的数据密钥和密文
+func (k *Keyring) Seal(plaintexts ...string) (string, string, []string, error) {
+	if !k.Enabled() {
+		return "", "", nil, errors.New("encryption is not enabled")
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	ciphertexts := make([]string, 0, len(plaintexts))
+	for _, plaintext := range plaintexts {
+		ciphertext, err := seal(aead, []byte(plaintext))
+		if err != nil {
+			return "", "", nil, err
+		}
+		ciphertexts = append(ciphertexts, ciphertextPrefix+ciphertext)
+	}
+
+	wrapped, err := seal(k.keys[k.activeKeyID], dataKey)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return k.activeKeyID, wrapped, ciphertexts, nil
+}
+
+// Open 解密字段，不带密文前缀的字段视为明文原样返回
+func (k *Keyring) Open(keyID, wrappedDataKey string, ciphertexts ...string) ([]string, error) {
+	dataKey, err := k.unwrap(keyID, wrappedDataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintexts := make([]string, 0, len(ciphertexts))
+	for _, ciphertext := range ciphertexts {
+		encoded, ok := strings.CutPrefix(ciphertext, ciphertextPrefix)
+		if !ok {
+			plaintexts = append(plaintexts, ciphertext)
+			continue
+		}
+		plaintext, err := open(aead, encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt with key %q: %w", keyID, err)
+		}
+		plaintexts = append(plaintexts, string(plaintext))
+	}
+	return plaintexts, nil
+}
+
+// Rewrap 用当前主密钥重新加密数据密钥，返回新的主密钥 ID 和数据密钥
+func (k *Keyring) Rewrap(keyID, wrappedDataKey string) (string, string, error) {
+	if !k.Enabled() {
+		return "", "", errors.New("encryption is not enabled")
+	}
+	dataKey, err := k.unwrap(keyID, wrappedDataKey)
+	if err != nil {
+		return "", "", err
+	}
+	wrapped, err := seal(k.keys[k.activeKeyID], dataKey)
+	if err != nil {
+		return "", "", err
+	}
+	return k.activeKeyID, wrapped, nil
+}
+
+func (k *Keyring) unwrap(keyID, wrappedDataKey string) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	dataKey, err := open(aead, wrappedDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with key %q: %w", keyID, err)
+	}
+	return dataKey, nil
+}
+
+// loadKey 从环境变量或文件读取 base64 编码的 32 字节主密钥
+func loadKey(item config.EncryptionKey) ([]byte, error) {
+	var encoded string
+	switch {
+	case item.Env != "" && item.File != "":
+		return nil, errors.New("only one of env and file can be set")
+	case item.Env != "":
+		value, ok := os.LookupEnv(item.Env)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", item.Env)
+		}
+		encoded = value
+	case item.File != "":
+		content, err := os.ReadFile(item.File)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(content)
+	default:
+		return nil, errors.New("env or file is required")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密并返回 base64(nonce || ciphertext)
+func seal(aead cipher.AEAD, plaintext []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func open(aead cipher.AEAD, encoded string) ([]byte, error) {
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := content[:aead.NonceSize()], content[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
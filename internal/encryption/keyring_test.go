@@ -0,0 +1,336 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"message-pocket/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestKey 生成 base64 编码的随机主密钥
+func newTestKey(t *testing.T, size int) string {
+	t.Helper()
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestNewKeyring(t *testing.T) {
+	t.Setenv("TEST_KEY_1", newTestKey(t, keySize))
+	t.Setenv("TEST_KEY_SHORT", newTestKey(t, 16))
+	t.Setenv("TEST_KEY_INVALID", "not base64!")
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte(newTestKey(t, keySize)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		cfg         config.EncryptionConfig
+		wantEnabled bool
+		wantErr     string
+	}{
+		{
+			name: "no keys",
+			cfg:  config.EncryptionConfig{},
+		},
+		{
+			name:        "active env key",
+			cfg:         config.EncryptionConfig{ActiveKey: "k1", Keys: []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_1"}}},
+			wantEnabled: true,
+		},
+		{
+			name:        "active file key with trailing newline",
+			cfg:         config.EncryptionConfig{ActiveKey: "k1", Keys: []config.EncryptionKey{{ID: "k1", File: keyFile}}},
+			wantEnabled: true,
+		},
+		{
+			name: "keys without active key only decrypt",
+			cfg:  config.EncryptionConfig{Keys: []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_1"}}},
+		},
+		{
+			name:    "missing id",
+			cfg:     config.EncryptionConfig{Keys: []config.EncryptionKey{{Env: "TEST_KEY_1"}}},
+			wantErr: "encryption.keys[0]: id is required",
+		},
+		{
+			name: "duplicate id",
+			cfg: config.EncryptionConfig{Keys: []config.EncryptionKey{
+				{ID: "k1", Env: "TEST_KEY_1"},
+				{ID: "k1", File: keyFile},
+			}},
+			wantErr: `encryption.keys[1]: duplicate id "k1"`,
+		},
+		{
+			name:    "both env and file",
+			cfg:     config.EncryptionConfig{Keys: []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_1", File: keyFile}}},
+			wantErr: "only one of env and file can be set",
+		},
+		{
+			name:    "neither env nor file",
+			cfg:     config.EncryptionConfig{Keys: []config.EncryptionKey{{ID: "k1"}}},
+			wantErr: "env or file is required",
+		},
+		{
+			name:    "env not set",
+			cfg:     config.EncryptionConfig{Keys: []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_MISSING"}}},
+			wantErr: "environment variable TEST_KEY_MISSING is not set",
+		},
+		{
+			name:    "invalid base64",
+			cfg:     config.EncryptionConfig{Keys: []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_INVALID"}}},
+			wantErr: "key is not valid base64",
+		},
+		{
+			name:    "wrong key size",
+			cfg:     config.EncryptionConfig{Keys: []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_SHORT"}}},
+			wantErr: "key must be 32 bytes, got 16",
+		},
+		{
+			name:    "active key not configured",
+			cfg:     config.EncryptionConfig{ActiveKey: "k2", Keys: []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_1"}}},
+			wantErr: `encryption.active_key: key "k2" is not configured`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := NewKeyring(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewKeyring() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewKeyring() error = %v", err)
+			}
+			if keyring.Enabled() != tt.wantEnabled {
+				t.Errorf("Enabled() = %v, want %v", keyring.Enabled(), tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	t.Setenv("TEST_KEY_1", newTestKey(t, keySize))
+	keyring, err := NewKeyring(config.EncryptionConfig{
+		ActiveKey: "k1",
+		Keys:      []config.EncryptionKey{{ID: "k1", Env: "TEST_KEY_1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		plaintexts []string
+	}{
+		{name: "single field", plaintexts: []string{"deployment failed"}},
+		{name: "multiple fields", plaintexts: []string{"部署失败", `{"eventType":"deployment.failed"}`}},
+		{name: "empty field", plaintexts: []string{""}},
+		{name: "long field", plaintexts: []string{strings.Repeat("x", 1<<16)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyID, dataKey, ciphertexts, err := keyring.Seal(tt.plaintexts...)
+			if err != nil {
+				t.Fatalf("Seal() error = %v", err)
+			}
+			if keyID != "k1" {
+				t.Errorf("Seal() keyID = %q, want k1", keyID)
+			}
+			for idx, ciphertext := range ciphertexts {
+				if !strings.HasPrefix(ciphertext, ciphertextPrefix) {
+					t.Errorf("ciphertext %d = %q, want prefix %q", idx, ciphertext, ciphertextPrefix)
+				}
+				if tt.plaintexts[idx] != "" && strings.Contains(ciphertext, tt.plaintexts[idx]) {
+					t.Errorf("ciphertext %d contains the plaintext", idx)
+				}
+			}
+
+			opened, err := keyring.Open(keyID, dataKey, ciphertexts...)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			for idx := range tt.plaintexts {
+				if opened[idx] != tt.plaintexts[idx] {
+					t.Errorf("Open() field %d = %q, want %q", idx, opened[idx], tt.plaintexts[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyringOpen(t *testing.T) {
+	t.Setenv("TEST_KEY_1", newTestKey(t, keySize))
+	t.Setenv("TEST_KEY_2", newTestKey(t, keySize))
+	keyring, err := NewKeyring(config.EncryptionConfig{
+		ActiveKey: "k1",
+		Keys: []config.EncryptionKey{
+			{ID: "k1", Env: "TEST_KEY_1"},
+			{ID: "k2", Env: "TEST_KEY_2"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, dataKey, ciphertexts, err := keyring.Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		keyID       string
+		dataKey     string
+		ciphertexts []string
+		want        []string
+		wantErr     error
+	}{
+		{
+			name:        "ciphertext",
+			keyID:       keyID,
+			dataKey:     dataKey,
+			ciphertexts: ciphertexts,
+			want:        []string{"secret"},
+		},
+		{
+			name:        "legacy plaintext is returned as is",
+			keyID:       keyID,
+			dataKey:     dataKey,
+			ciphertexts: []string{"written before encryption"},
+			want:        []string{"written before encryption"},
+		},
+		{
+			name:        "unknown key",
+			keyID:       "k3",
+			dataKey:     dataKey,
+			ciphertexts: ciphertexts,
+			wantErr:     ErrUnknownKey,
+		},
+		{
+			name:        "data key wrapped by another key",
+			keyID:       "k2",
+			dataKey:     dataKey,
+			ciphertexts: ciphertexts,
+		},
+		{
+			name:        "tampered ciphertext",
+			keyID:       keyID,
+			dataKey:     dataKey,
+			ciphertexts: []string{ciphertextPrefix + base64.StdEncoding.EncodeToString(make([]byte, 40))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyring.Open(tt.keyID, tt.dataKey, tt.ciphertexts...)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("Open() = %v, want error", got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			for idx := range tt.want {
+				if got[idx] != tt.want[idx] {
+					t.Errorf("Open() field %d = %q, want %q", idx, got[idx], tt.want[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyringRewrap(t *testing.T) {
+	t.Setenv("TEST_KEY_OLD", newTestKey(t, keySize))
+	t.Setenv("TEST_KEY_NEW", newTestKey(t, keySize))
+	oldKey := config.EncryptionKey{ID: "old", Env: "TEST_KEY_OLD"}
+	newKey := config.EncryptionKey{ID: "new", Env: "TEST_KEY_NEW"}
+	keysByID := map[string]config.EncryptionKey{"old": oldKey, "new": newKey}
+
+	before, err := NewKeyring(config.EncryptionConfig{ActiveKey: "old", Keys: []config.EncryptionKey{oldKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, dataKey, ciphertexts, err := before.Seal("rotate me")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.EncryptionConfig
+		wantErr bool
+	}{
+		{
+			name: "rotate to new key",
+			cfg:  config.EncryptionConfig{ActiveKey: "new", Keys: []config.EncryptionKey{oldKey, newKey}},
+		},
+		{
+			name: "rewrap with the same key",
+			cfg:  config.EncryptionConfig{ActiveKey: "old", Keys: []config.EncryptionKey{oldKey}},
+		},
+		{
+			name:    "old key removed before rotation",
+			cfg:     config.EncryptionConfig{ActiveKey: "new", Keys: []config.EncryptionKey{newKey}},
+			wantErr: true,
+		},
+		{
+			name:    "encryption disabled",
+			cfg:     config.EncryptionConfig{Keys: []config.EncryptionKey{oldKey}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := NewKeyring(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newKeyID, newDataKey, err := keyring.Rewrap(keyID, dataKey)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Rewrap() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rewrap() error = %v", err)
+			}
+			if newKeyID != tt.cfg.ActiveKey {
+				t.Errorf("Rewrap() keyID = %q, want %q", newKeyID, tt.cfg.ActiveKey)
+			}
+
+			// 只保留当前主密钥也能解密重新加密后的数据
+			activeOnly, err := NewKeyring(config.EncryptionConfig{
+				ActiveKey: tt.cfg.ActiveKey,
+				Keys:      []config.EncryptionKey{keysByID[tt.cfg.ActiveKey]},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opened, err := activeOnly.Open(newKeyID, newDataKey, ciphertexts...)
+			if err != nil {
+				t.Fatalf("Open() after Rewrap() error = %v", err)
+			}
+			if opened[0] != "rotate me" {
+				t.Errorf("Open() after Rewrap() = %q, want %q", opened[0], "rotate me")
+			}
+		})
+	}
+}
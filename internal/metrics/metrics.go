@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"message-pocket/internal/repo"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/samber/do/v2"
+)
+
+// namespace 指标名前缀
+const namespace = "message_pocket"
+
+// 发送结果
+const (
+	OutcomeSent        = "sent"
+	OutcomeFailed      = "failed"
+	OutcomeRateLimited = "rate_limited"
+	OutcomeCircuitOpen = "circuit_open"
+)
+
+// Metrics Prometheus 指标，使用独立的 Registry，避免与依赖库注册到默认 Registry 的指标混在一起
+type Metrics struct {
+	registry *prometheus.Registry
+
+	eventsReceived  *prometheus.CounterVec
+	deliveries      *prometheus.CounterVec
+	retryAttempts   *prometheus.CounterVec
+	napcatLatency   *prometheus.HistogramVec
+	deliveryLatency *prometheus.HistogramVec
+
+	circuitState       *prometheus.GaugeVec
+	circuitTransitions *prometheus.CounterVec
+}
+
+// NewMetrics 创建并注册指标，消息积压数在抓取时从 message_box 统计
+func NewMetrics(messageBoxRepo repo.IMessageBoxRepo) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_received_total",
+			Help:      "Events received by source and event type.",
+		}, []string{"source", "event_type"}),
+		deliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deliveries_total",
+			Help:      "Delivery attempts by destination and outcome (sent, failed, rate_limited, circuit_open).",
+		}, []string{"destination", "outcome"}),
+		retryAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retry_attempts_total",
+			Help:      "Messages picked up by the retry job by destination.",
+		}, []string{"destination"}),
+		napcatLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "napcat_request_duration_seconds",
+			Help:      "NapCat API call latency by endpoint and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint", "outcome"}),
+		deliveryLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "delivery_latency_seconds",
+			Help:      "Time from message creation to successful delivery by destination.",
+			Buckets:   []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600, 4 * 3600, 12 * 3600},
+		}, []string{"destination"}),
+		circuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "circuit_state",
+			Help:      "Circuit breaker state by destination and target, 1 for the current state.",
+		}, []string{"destination", "target", "state"}),
+		circuitTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "circuit_transitions_total",
+			Help:      "Circuit breaker state transitions by destination, target and new state.",
+		}, []string{"destination", "target", "state"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.eventsReceived,
+		m.deliveries,
+		m.retryAttempts,
+		m.napcatLatency,
+		m.deliveryLatency,
+		m.circuitState,
+		m.circuitTransitions,
+		newMessageCollector(messageBoxRepo),
+	)
+
+	return m
+}
+
+func ProvideMetrics(i do.Injector) (*Metrics, error) {
+	messageBoxRepo := do.MustInvoke[repo.IMessageBoxRepo](i)
+	return NewMetrics(messageBoxRepo), nil
+}
+
+// Handler 指标抓取接口
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// EventReceived 记录收到的事件，非 webhook 来源的 eventType 为空
+func (m *Metrics) EventReceived(source, eventType string) {
+	m.eventsReceived.WithLabelValues(source, eventType).Inc()
+}
+
+// DeliveryFinished 记录一次发送结果
+func (m *Metrics) DeliveryFinished(destination, outcome string) {
+	m.deliveries.WithLabelValues(destination, outcome).Inc()
+}
+
+// RetryAttempted 记录重试任务处理的消息
+func (m *Metrics) RetryAttempted(destination string) {
+	m.retryAttempts.WithLabelValues(destination).Inc()
+}
+
+// ObserveNapCat 记录 NapCat 接口耗时
+func (m *Metrics) ObserveNapCat(endpoint, outcome string, duration time.Duration) {
+	m.napcatLatency.WithLabelValues(endpoint, outcome).Observe(duration.Seconds())
+}
+
+// ObserveDeliveryLatency 记录消息从创建到发送成功的耗时
+func (m *Metrics) ObserveDeliveryLatency(destination string, latency time.Duration) {
+	m.deliveryLatency.WithLabelValues(destination).Observe(latency.Seconds())
+}
+
+// CircuitTransition 记录熔断状态变化，from 为空表示新建的熔断器，只设置状态不计入状态变化次数
+func (m *Metrics) CircuitTransition(destination, target, from, to string) {
+	if from != "" {
+		m.circuitState.WithLabelValues(destination, target, from).Set(0)
+		m.circuitTransitions.WithLabelValues(destination, target, to).Inc()
+	}
+	m.circuitState.WithLabelValues(destination, target, to).Set(1)
+}
+
+// messageCollector 抓取时按状态和目的地统计消息数，pending 为待发送积压，expired 和 dead_letter 为放弃重试的死信
+type messageCollector struct {
+	messageBoxRepo repo.IMessageBoxRepo
+	desc           *prometheus.Desc
+}
+
+func newMessageCollector(messageBoxRepo repo.IMessageBoxRepo) *messageCollector {
+	return &messageCollector{
+		messageBoxRepo: messageBoxRepo,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "messages"),
+			"Messages in message_box by status and destination.",
+			[]string{"status", "destination"},
+			nil,
+		),
+	}
+}
+
+func (c *messageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *messageCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := c.messageBoxRepo.CountByStatus(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count messages for metrics", "err", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count.Count),
+			count.Status.String(), count.DestinationType.String())
+	}
+}
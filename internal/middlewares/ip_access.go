@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"log/slog"
+	"message-pocket/internal/services"
+	"net"
+	"net/netip"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ClientIPKey 请求上下文中客户端 IP 的键
+const ClientIPKey = "client_ip"
+
+// IPAccessMiddleware 按路由分组的 CIDR 规则限制访问来源，拒绝时返回 403
+// 同时确定客户端 IP 供后续中间件使用：直连地址为可信代理时才按 PocketBase 的可信代理设置读取转发头
+func IPAccessMiddleware(ipAccessService *services.IPAccessService, group string) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		ctx := e.Request.Context()
+
+		addr := clientAddr(e, ipAccessService)
+		e.Set(ClientIPKey, addr.String())
+
+		if allowed, reason := ipAccessService.Check(group, addr); !allowed {
+			slog.WarnContext(ctx, "Request rejected by IP access rules",
+				"group", group,
+				"ip", addr.String(),
+				"remote_addr", e.Request.RemoteAddr,
+				"path", e.Request.URL.Path,
+				"reason", reason)
+			return e.ForbiddenError("access from this IP is not allowed", nil)
+		}
+
+		return e.Next()
+	}
+}
+
+// ClientIP 获取客户端 IP，未经过 IPAccessMiddleware 时按 PocketBase 的设置获取
+func ClientIP(e *core.RequestEvent) string {
+	if ip, ok := e.Get(ClientIPKey).(string); ok {
+		return ip
+	}
+	return e.RealIP()
+}
+
+func clientAddr(e *core.RequestEvent, ipAccessService *services.IPAccessService) netip.Addr {
+	host, _, err := net.SplitHostPort(e.Request.RemoteAddr)
+	if err != nil {
+		host = e.Request.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		// 无法解析直连地址时交给 PocketBase 处理
+		remote, _ = netip.ParseAddr(e.RealIP())
+		return remote.Unmap()
+	}
+	remote = remote.Unmap()
+
+	if !ipAccessService.TrustsProxy(remote) {
+		return remote
+	}
+	if forwarded, err := netip.ParseAddr(e.RealIP()); err == nil {
+		return forwarded.Unmap()
+	}
+	return remote
+}
@@ -1,28 +1,151 @@
 package middlewares
 
 import (
-	"message-pocket/internal/config"
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/constants/api_token_enum"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/redact"
+	"message-pocket/internal/services"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 )
 
-func TokenAuthMiddleware() func(e *core.RequestEvent) error {
+// APITokenKey 请求上下文中认证通过的令牌的键
+const APITokenKey = "api_token"
+
+// queryTokenKey 请求上下文中从查询参数取出的令牌的键
+const queryTokenKey = "query_token"
+
+// queryTokenParam 携带令牌的查询参数
+const queryTokenParam = "token"
+
+// TokenSource 令牌的携带方式
+type TokenSource int
+
+const (
+	// TokenFromBearer Authorization: Bearer <token>
+	TokenFromBearer TokenSource = iota + 1
+	// TokenFromHeader X-Token: <token>
+	TokenFromHeader
+	// TokenFromQuery ?token=<token>，只用于无法设置请求头的来源，需要先经过 MaskQueryTokenMiddleware
+	TokenFromQuery
+)
+
+// TokenAuthOptions 令牌认证选项
+type TokenAuthOptions struct {
+	// Scope 路由要求的令牌范围
+	Scope api_token_enum.Scope
+	// Sources 允许的令牌携带方式，为空时只允许请求头
+	Sources []TokenSource
+}
+
+// TokenAuthMiddleware 校验令牌并要求令牌拥有指定范围
+// 未携带令牌或令牌无效、过期、吊销时返回 401，范围不足时返回 403，
+// 同一 IP 连续认证失败被锁定时返回 429
+func TokenAuthMiddleware(
+	tokenService *services.TokenService,
+	lockoutService *services.AuthLockoutService,
+	opts TokenAuthOptions,
+) func(e *core.RequestEvent) error {
+	sources := opts.Sources
+	if len(sources) == 0 {
+		sources = []TokenSource{TokenFromBearer, TokenFromHeader}
+	}
+
 	return func(e *core.RequestEvent) error {
-		authHeader := e.Request.Header.Get("Authorization")
+		ctx := e.Request.Context()
+		ip := ClientIP(e)
+		now := time.Now()
 
-		split := strings.Split(authHeader, " ")
-		if len(split) != 2 {
-			return e.UnauthorizedError("unauthorized", nil)
+		if lockedFor := lockoutService.LockedFor(ip, now); lockedFor > 0 {
+			e.Response.Header().Set("Retry-After", strconv.Itoa(int(lockedFor.Round(time.Second).Seconds())))
+			return e.TooManyRequestsError("too many failed authentication attempts", nil)
 		}
 
-		token := split[1]
+		secret := extractToken(e, sources)
+		if secret == "" {
+			e.Response.Header().Set("WWW-Authenticate", `Bearer realm="message-pocket"`)
+			return e.UnauthorizedError("missing token", nil)
+		}
+
+		token, err := tokenService.Authenticate(ctx, secret)
+		if err != nil {
+			if !errors.Is(err, services.ErrTokenInvalid) && !errors.Is(err, services.ErrTokenExpired) && !errors.Is(err, services.ErrTokenRevoked) {
+				slog.ErrorContext(ctx, "Failed to authenticate token", "err", err)
+				return e.InternalServerError("failed to authenticate token", nil)
+			}
+
+			// 只有未知令牌计入暴力猜测，过期和吊销的令牌说明调用方持有过真实令牌
+			// 具体原因只记录日志，响应不区分，避免猜测者据此判断令牌是否存在
+			locked := errors.Is(err, services.ErrTokenInvalid) && lockoutService.RecordFailure(ip, now)
+			slog.WarnContext(ctx, "Token authentication failed",
+				"reason", err.Error(),
+				"ip", ip,
+				"path", e.Request.URL.Path,
+				"locked", locked)
+			e.Response.Header().Set("WWW-Authenticate", `Bearer realm="message-pocket", error="invalid_token"`)
+			return e.UnauthorizedError("invalid token", nil)
+		}
+		lockoutService.Reset(ip)
 
-		c := config.GetConfig()
-		if c.ServerConfig.OpenToken != token {
-			return e.UnauthorizedError("unauthorized", nil)
+		if !token.HasScope(opts.Scope) {
+			slog.WarnContext(ctx, "Token scope denied",
+				"token", token.Name,
+				"scope", opts.Scope,
+				"ip", ip,
+				"path", e.Request.URL.Path)
+			return e.ForbiddenError(fmt.Sprintf("token does not have the %s scope", opts.Scope), nil)
 		}
 
+		e.Set(APITokenKey, token)
 		return e.Next()
 	}
 }
+
+// extractToken 按允许的携带方式依次提取令牌
+func extractToken(e *core.RequestEvent, sources []TokenSource) string {
+	for _, source := range sources {
+		var token string
+		switch source {
+		case TokenFromBearer:
+			scheme, credentials, ok := strings.Cut(strings.TrimSpace(e.Request.Header.Get("Authorization")), " ")
+			if ok && strings.EqualFold(scheme, "Bearer") {
+				token = strings.TrimSpace(credentials)
+			}
+		case TokenFromHeader:
+			token = strings.TrimSpace(e.Request.Header.Get("X-Token"))
+		case TokenFromQuery:
+			token, _ = e.Get(queryTokenKey).(string)
+		}
+		if token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// MaskQueryTokenMiddleware 取出查询参数中的令牌后在 URL 中替换为掩码
+// PocketBase 的请求日志在路由执行完后记录完整的 URL，不经过脱敏 handler，
+// 需要绑定在路由分组上，先于 IP 访问控制和令牌认证执行
+func MaskQueryTokenMiddleware() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		query := e.Request.URL.Query()
+		if query.Has(queryTokenParam) {
+			e.Set(queryTokenKey, query.Get(queryTokenParam))
+			query.Set(queryTokenParam, redact.Mask)
+			e.Request.URL.RawQuery = query.Encode()
+		}
+		return e.Next()
+	}
+}
+
+// GetAPIToken 获取认证通过的令牌，未经过 TokenAuthMiddleware 时返回 nil
+func GetAPIToken(e *core.RequestEvent) *model.APITokenModel {
+	token, _ := e.Get(APITokenKey).(*model.APITokenModel)
+	return token
+}
@@ -1,48 +1,47 @@
 package middlewares
 
 import (
-	"context"
-	"fmt"
-	"math/rand"
-	"time"
+	"message-pocket/internal/tracing"
 
 	"github.com/pocketbase/pocketbase/core"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// TraceIDKey context中trace_id的键
-const TraceIDKey = "trace_id"
-
-// TraceMiddleware 生成trace_id并存入context的中间件
+// TraceMiddleware 为请求创建 server span，沿用请求头中的 W3C traceparent，没有时开始新的 trace
 func TraceMiddleware() func(e *core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
-		// 生成trace_id
-		traceID := generateTraceID()
-
-		// 将trace_id存入context
-		ctx := context.WithValue(e.Request.Context(), TraceIDKey, traceID)
+		route := e.Request.Pattern
+		if route == "" {
+			route = e.Request.URL.Path
+		}
+
+		ctx := tracing.Extract(e.Request.Context(), e.Request.Header)
+		ctx, span := tracing.Start(ctx, route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", e.Request.Method),
+				attribute.String("url.path", e.Request.URL.Path),
+				attribute.String("http.route", route),
+			),
+		)
+		defer span.End()
 
 		// 更新请求的context
 		e.Request = e.Request.WithContext(ctx)
 
-		// 记录日志（可选）
-		e.App.Logger().DebugContext(ctx, "Trace middleware executed", "trace_id", traceID)
-
-		return e.Next()
-	}
-}
+		err := e.Next()
 
-// generateTraceID 生成唯一的trace_id
-func generateTraceID() string {
-	// 使用时间戳和随机数生成简单的trace_id
-	timestamp := time.Now().UnixNano()
-	random := rand.Intn(10000)
-	return fmt.Sprintf("trace-%d-%04d", timestamp, random)
-}
+		status := e.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if err != nil || status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
 
-// GetTraceIDFromContext 从context中获取trace_id
-func GetTraceIDFromContext(ctx context.Context) string {
-	if traceID, ok := ctx.Value(TraceIDKey).(string); ok {
-		return traceID
+		return err
 	}
-	return ""
 }
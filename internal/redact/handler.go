@@ -0,0 +1,84 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Handler 在写出日志前对消息和属性脱敏
+type Handler struct {
+	next     slog.Handler
+	redactor *atomic.Pointer[Redactor]
+}
+
+// NewHandler 包装 next，加载配置后可通过 SetRedactor 替换规则
+func NewHandler(next slog.Handler, redactor *Redactor) *Handler {
+	h := &Handler{
+		next:     next,
+		redactor: &atomic.Pointer[Redactor]{},
+	}
+	h.redactor.Store(redactor)
+	return h
+}
+
+// SetRedactor 替换脱敏规则，对 WithAttrs/WithGroup 派生的 Handler 同样生效
+func (h *Handler) SetRedactor(redactor *Redactor) {
+	h.redactor.Store(redactor)
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	redactor := h.redactor.Load()
+
+	record := slog.NewRecord(r.Time, r.Level, redactor.String(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		record.AddAttrs(redactor.Attr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactor := h.redactor.Load()
+
+	redacted := make([]slog.Attr, len(attrs))
+	for idx, attr := range attrs {
+		redacted[idx] = redactor.Attr(attr)
+	}
+	return &Handler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+// Attr 对单个日志属性脱敏
+func (r *Redactor) Attr(attr slog.Attr) slog.Attr {
+	if r.IsSensitiveKey(attr.Key) {
+		if attr.Value.Kind() == slog.KindString && attr.Value.String() == "" {
+			return attr
+		}
+		return slog.String(attr.Key, Mask)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, r.String(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for idx, item := range group {
+			redacted[idx] = r.Attr(item)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		return slog.Any(attr.Key, r.Any(value.Any()))
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
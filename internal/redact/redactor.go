@@ -0,0 +1,247 @@
+package redact
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"message-pocket/internal/config"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/samber/do/v2"
+)
+
+// Mask 替换敏感内容的占位符
+const Mask = "[REDACTED]"
+
+// maxDepth 遍历嵌套结构的最大深度，防止循环引用
+const maxDepth = 16
+
+// defaultKeys 内置的敏感字段，字段名归一化后以其结尾即视为敏感，如 open_token、OpenToken、X-Token
+var defaultKeys = []string{
+	"token",
+	"password",
+	"secret",
+	"authorization",
+	"cookie",
+	"apikey",
+	"datakey",
+	"privatekey",
+}
+
+// defaultPatterns 内置的敏感内容正则，匹配的部分会被替换
+var defaultPatterns = []string{
+	// Authorization 头中的 Bearer 令牌
+	`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`,
+	// 本服务签发的 API 令牌
+	`mp_[A-Za-z0-9]{40}`,
+}
+
+// Redactor 按字段名和正则脱敏，结构体字段也可以通过 `redact:"true"` 标签标记为敏感
+type Redactor struct {
+	keys     []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor 创建脱敏器，keys 和 patterns 追加在内置规则之后
+func NewRedactor(keys, patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, key := range append(append([]string{}, defaultKeys...), keys...) {
+		if key = normalizeKey(key); key != "" {
+			r.keys = append(r.keys, key)
+		}
+	}
+	for idx, pattern := range append(append([]string{}, defaultPatterns...), patterns...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			if idx >= len(defaultPatterns) {
+				return nil, fmt.Errorf("patterns[%d]: %w", idx-len(defaultPatterns), err)
+			}
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Default 只包含内置规则的脱敏器，用于加载配置之前
+func Default() *Redactor {
+	r, err := NewRedactor(nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Redactors 日志和原始请求分别使用的脱敏器
+type Redactors struct {
+	Logs *Redactor
+	// SourceRequest 保存前对 source_request 脱敏，未启用时为 nil
+	SourceRequest *Redactor
+}
+
+// NewRedactors 从配置创建脱敏器
+func NewRedactors(cfg config.RedactionConfig) (*Redactors, error) {
+	logs, err := NewRedactor(cfg.Keys, cfg.Patterns)
+	if err != nil {
+		return nil, fmt.Errorf("redaction.%w", err)
+	}
+	redactors := &Redactors{Logs: logs}
+
+	if cfg.SourceRequest.Enabled {
+		keys := append(append([]string{}, cfg.Keys...), cfg.SourceRequest.Keys...)
+		patterns := append(append([]string{}, cfg.Patterns...), cfg.SourceRequest.Patterns...)
+		if redactors.SourceRequest, err = NewRedactor(keys, patterns); err != nil {
+			return nil, fmt.Errorf("redaction.source_request: %w", err)
+		}
+	}
+
+	return redactors, nil
+}
+
+func ProvideRedactors(i do.Injector) (*Redactors, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	return NewRedactors(cfg.Redaction)
+}
+
+// String 替换字符串中匹配正则的部分
+func (r *Redactor) String(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// IsSensitiveKey 字段名是否敏感，比较时忽略大小写和 _ - . 分隔符
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range r.keys {
+		if strings.HasSuffix(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON 对 JSON 文本脱敏，不是合法 JSON 时只按正则替换
+func (r *Redactor) JSON(s string) string {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return r.String(s)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(r.Any(value)); err != nil {
+		return r.String(s)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Any 返回脱敏后的值，结构体和 map 转换为 map[string]any，切片转换为 []any，其余类型原样返回
+func (r *Redactor) Any(value any) any {
+	return r.walk(reflect.ValueOf(value), 0)
+}
+
+var (
+	errorType         = reflect.TypeFor[error]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	jsonNumberType    = reflect.TypeFor[json.Number]()
+)
+
+func (r *Redactor) walk(v reflect.Value, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxDepth {
+		return Mask
+	}
+
+	// 自定义序列化的类型（如时间）不展开，错误按文本脱敏
+	if v.Type().Implements(errorType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil
+		}
+		return r.String(v.Interface().(error).Error())
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if v.Type() == jsonNumberType {
+			return v.Interface()
+		}
+		return r.String(v.String())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return r.walk(v.Elem(), depth+1)
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		for idx := 0; idx < v.NumField(); idx++ {
+			field := v.Type().Field(idx)
+			if !field.IsExported() {
+				continue
+			}
+			name := field.Name
+			if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			if field.Tag.Get("redact") == "true" || r.IsSensitiveKey(field.Name) || r.IsSensitiveKey(name) {
+				out[name] = r.mask(v.Field(idx))
+				continue
+			}
+			out[name] = r.walk(v.Field(idx), depth+1)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if r.IsSensitiveKey(key) {
+				out[key] = r.mask(iter.Value())
+				continue
+			}
+			out[key] = r.walk(iter.Value(), depth+1)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			return v.Interface()
+		}
+		out := make([]any, v.Len())
+		for idx := range out {
+			out[idx] = r.walk(v.Index(idx), depth+1)
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// mask 空值保持为空，便于区分未配置和已配置
+func (r *Redactor) mask(v reflect.Value) any {
+	if !v.IsValid() || v.IsZero() {
+		return ""
+	}
+	return Mask
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(key))
+}
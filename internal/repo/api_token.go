@@ -0,0 +1,181 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/tracing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+type IAPITokenRepo interface {
+	Create(ctx context.Context, in CreateAPITokenIn) (*model.APITokenModel, error)
+	List(ctx context.Context) ([]*model.APITokenModel, error)
+	GetByID(ctx context.Context, tokenID string) (*model.APITokenModel, error)
+	GetByHash(ctx context.Context, tokenHash string) (*model.APITokenModel, error)
+	GetByName(ctx context.Context, name string) (*model.APITokenModel, error)
+	UpdateByID(ctx context.Context, tokenID string, data map[string]any) error
+}
+
+// APITokenRepo api_token 是 PocketBase 集合，超级管理员可以在管理后台查看
+type APITokenRepo struct {
+	app core.App
+}
+
+// apiTokenCollection 令牌集合名称
+const apiTokenCollection = "api_token"
+
+func NewAPITokenRepo(app core.App) *APITokenRepo {
+	return &APITokenRepo{
+		app: app,
+	}
+}
+
+func ProvideAPITokenRepo(i do.Injector) (*APITokenRepo, error) {
+	app := do.MustInvoke[core.App](i)
+	return NewAPITokenRepo(app), nil
+}
+
+// apiTokenColumns 查询令牌时的列
+var apiTokenColumns = []string{
+	"id",
+	"name",
+	"token_prefix",
+	"token_hash",
+	"scopes",
+	"expires_at",
+	"last_used_at",
+	"revoked_at",
+	"created_by",
+	"created_at",
+}
+
+type CreateAPITokenIn struct {
+	Name        string
+	TokenPrefix string
+	TokenHash   string
+	Scopes      []string
+	// ExpiresAt 零值表示不过期
+	ExpiresAt time.Time
+	CreatedBy string
+}
+
+func (m *APITokenRepo) Create(ctx context.Context, in CreateAPITokenIn) (*model.APITokenModel, error) {
+	ctx, span := tracing.Start(ctx, "APITokenRepo.Create")
+	defer span.End()
+
+	collection, err := m.app.FindCachedCollectionByNameOrId(apiTokenCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先创建 APITokenModel
+	var expiresAt int64
+	if !in.ExpiresAt.IsZero() {
+		expiresAt = in.ExpiresAt.Unix()
+	}
+	token := &model.APITokenModel{
+		Name:        in.Name,
+		TokenPrefix: in.TokenPrefix,
+		TokenHash:   in.TokenHash,
+		Scopes:      in.Scopes,
+		ExpiresAt:   expiresAt,
+		CreatedBy:   in.CreatedBy,
+		CreatedAt:   time.Now().Unix(),
+	}
+
+	// 使用 APITokenModel 的值构建 Record，ID 由 PocketBase 生成
+	record := core.NewRecord(collection)
+	record.Load(map[string]any{
+		"name":         token.Name,
+		"token_prefix": token.TokenPrefix,
+		"token_hash":   token.TokenHash,
+		"scopes":       token.Scopes,
+		"expires_at":   token.ExpiresAt,
+		"created_by":   token.CreatedBy,
+		"created_at":   token.CreatedAt,
+	})
+	if err := m.app.SaveWithContext(ctx, record); err != nil {
+		return nil, err
+	}
+
+	token.ID = record.Id
+	return token, nil
+}
+
+// List 查询全部令牌，包括已吊销和已过期的
+func (m *APITokenRepo) List(ctx context.Context) ([]*model.APITokenModel, error) {
+	ctx, span := tracing.Start(ctx, "APITokenRepo.List")
+	defer span.End()
+
+	tokens := make([]*model.APITokenModel, 0)
+	if err := m.app.DB().Select(apiTokenColumns...).
+		From("api_token").
+		OrderBy("created_at DESC", "id DESC").
+		WithContext(ctx).
+		All(&tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// GetByID 按 ID 查询令牌，不存在时返回 nil
+func (m *APITokenRepo) GetByID(ctx context.Context, tokenID string) (*model.APITokenModel, error) {
+	ctx, span := tracing.Start(ctx, "APITokenRepo.GetByID")
+	defer span.End()
+
+	return m.getOne(ctx, dbx.HashExp{"id": tokenID})
+}
+
+// GetByHash 按令牌哈希查询令牌，不存在时返回 nil
+func (m *APITokenRepo) GetByHash(ctx context.Context, tokenHash string) (*model.APITokenModel, error) {
+	ctx, span := tracing.Start(ctx, "APITokenRepo.GetByHash")
+	defer span.End()
+
+	return m.getOne(ctx, dbx.HashExp{"token_hash": tokenHash})
+}
+
+// GetByName 按名称查询令牌，不存在时返回 nil
+func (m *APITokenRepo) GetByName(ctx context.Context, name string) (*model.APITokenModel, error) {
+	ctx, span := tracing.Start(ctx, "APITokenRepo.GetByName")
+	defer span.End()
+
+	return m.getOne(ctx, dbx.HashExp{"name": name})
+}
+
+func (m *APITokenRepo) getOne(ctx context.Context, where dbx.Expression) (*model.APITokenModel, error) {
+	token := &model.APITokenModel{}
+	err := m.app.DB().Select(apiTokenColumns...).
+		From("api_token").
+		Where(where).
+		WithContext(ctx).
+		One(token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (m *APITokenRepo) UpdateByID(ctx context.Context, tokenID string, data map[string]any) error {
+	ctx, span := tracing.Start(ctx, "APITokenRepo.UpdateByID")
+	defer span.End()
+
+	record, err := m.app.FindRecordById(apiTokenCollection, tokenID)
+	if err != nil {
+		return err
+	}
+	for key, value := range data {
+		record.Set(key, value)
+	}
+	return m.app.SaveWithContext(ctx, record)
+}
@@ -0,0 +1,190 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/tracing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/samber/do/v2"
+)
+
+type IEODeploymentRepo interface {
+	Create(ctx context.Context, in CreateEODeploymentIn) (*model.EODeploymentModel, error)
+	GetByDeploymentID(ctx context.Context, projectID, deploymentID string) (*model.EODeploymentModel, error)
+	GetLastFinished(ctx context.Context, projectID, repoBranch string, excludeID int32) (*model.EODeploymentModel, error)
+	UpdateByID(ctx context.Context, deploymentID int32, data map[string]any) error
+}
+
+type EODeploymentRepo struct {
+	db dbx.Builder
+}
+
+func NewEODeploymentRepo(db dbx.Builder) *EODeploymentRepo {
+	return &EODeploymentRepo{
+		db: db,
+	}
+}
+
+func ProvideEODeploymentRepo(i do.Injector) (*EODeploymentRepo, error) {
+	db := do.MustInvoke[dbx.Builder](i)
+	return NewEODeploymentRepo(db), nil
+}
+
+type CreateEODeploymentIn struct {
+	ProjectID     string
+	DeploymentID  string
+	ProjectName   string
+	RepoBranch    string
+	LastEventType string
+	CreatedAt     time.Time
+}
+
+const eoDeploymentColumns = `
+				id,
+				project_id,
+				deployment_id,
+				project_name,
+				repo_branch,
+				last_event_type,
+				result,
+				fail_streak,
+				created_at,
+				build_started_at,
+				build_finished_at,
+				deploy_started_at,
+				finished_at,
+				updated_at`
+
+func (m *EODeploymentRepo) Create(ctx context.Context, in CreateEODeploymentIn) (*model.EODeploymentModel, error) {
+	ctx, span := tracing.Start(ctx, "EODeploymentRepo.Create")
+	defer span.End()
+
+	// 先创建 EODeploymentModel
+	deployment := &model.EODeploymentModel{
+		ID:            0, // 将在插入后更新
+		ProjectID:     in.ProjectID,
+		DeploymentID:  in.DeploymentID,
+		ProjectName:   in.ProjectName,
+		RepoBranch:    in.RepoBranch,
+		LastEventType: in.LastEventType,
+		CreatedAt:     in.CreatedAt.Unix(),
+		UpdatedAt:     time.Now().Unix(),
+	}
+
+	result, err := m.db.NewQuery(`
+		INSERT INTO eo_deployment (
+			project_id,
+			deployment_id,
+			project_name,
+			repo_branch,
+			last_event_type,
+			created_at,
+			updated_at
+		) VALUES (
+			{:project_id},
+			{:deployment_id},
+			{:project_name},
+			{:repo_branch},
+			{:last_event_type},
+			{:created_at},
+			{:updated_at}
+		)
+	`).
+		Bind(map[string]any{
+			"project_id":      deployment.ProjectID,
+			"deployment_id":   deployment.DeploymentID,
+			"project_name":    deployment.ProjectName,
+			"repo_branch":     deployment.RepoBranch,
+			"last_event_type": deployment.LastEventType,
+			"created_at":      deployment.CreatedAt,
+			"updated_at":      deployment.UpdatedAt,
+		}).
+		WithContext(ctx).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	deployment.ID = int32(id)
+	return deployment, nil
+}
+
+// GetByDeploymentID 按项目和部署 ID 查询部署状态，不存在时返回 nil
+func (m *EODeploymentRepo) GetByDeploymentID(ctx context.Context, projectID, deploymentID string) (*model.EODeploymentModel, error) {
+	ctx, span := tracing.Start(ctx, "EODeploymentRepo.GetByDeploymentID")
+	defer span.End()
+
+	deployment := &model.EODeploymentModel{}
+	err := m.db.NewQuery(`
+			SELECT` + eoDeploymentColumns + `
+			FROM eo_deployment
+			WHERE project_id = {:project_id}
+			AND deployment_id = {:deployment_id}
+		`).
+		Bind(map[string]any{
+			"project_id":    projectID,
+			"deployment_id": deploymentID,
+		}).
+		WithContext(ctx).
+		One(deployment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// GetLastFinished 查询同一项目同一分支上最近一次已结束的部署，不存在时返回 nil
+func (m *EODeploymentRepo) GetLastFinished(ctx context.Context, projectID, repoBranch string, excludeID int32) (*model.EODeploymentModel, error) {
+	ctx, span := tracing.Start(ctx, "EODeploymentRepo.GetLastFinished")
+	defer span.End()
+
+	deployment := &model.EODeploymentModel{}
+	err := m.db.NewQuery(`
+			SELECT` + eoDeploymentColumns + `
+			FROM eo_deployment
+			WHERE project_id = {:project_id}
+			AND repo_branch = {:repo_branch}
+			AND result != ''
+			AND id != {:exclude_id}
+			ORDER BY finished_at DESC, id DESC
+			LIMIT 1
+		`).
+		Bind(map[string]any{
+			"project_id":  projectID,
+			"repo_branch": repoBranch,
+			"exclude_id":  excludeID,
+		}).
+		WithContext(ctx).
+		One(deployment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+func (m *EODeploymentRepo) UpdateByID(ctx context.Context, deploymentID int32, data map[string]any) error {
+	ctx, span := tracing.Start(ctx, "EODeploymentRepo.UpdateByID")
+	defer span.End()
+
+	_, err := m.db.Update("eo_deployment", data, dbx.NewExp("id = {:id}", dbx.Params{"id": deploymentID})).
+		WithContext(ctx).
+		Execute()
+	return err
+}
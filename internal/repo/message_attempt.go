@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/tracing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+type IMessageAttemptRepo interface {
+	Create(ctx context.Context, in CreateMessageAttemptIn) (*model.MessageAttemptModel, error)
+	ListByMessageID(ctx context.Context, messageID string) ([]*model.MessageAttemptModel, error)
+	LatestByDestination(ctx context.Context, destinationType message_box_enum.DestinationType, failed bool) (*model.MessageAttemptModel, error)
+}
+
+// MessageAttemptRepo message_attempt 是 PocketBase 集合，记录每次调用目的地的结果
+type MessageAttemptRepo struct {
+	app core.App
+}
+
+// messageAttemptCollection 发送历史集合名称
+const messageAttemptCollection = "message_attempt"
+
+func NewMessageAttemptRepo(app core.App) *MessageAttemptRepo {
+	return &MessageAttemptRepo{
+		app: app,
+	}
+}
+
+func ProvideMessageAttemptRepo(i do.Injector) (*MessageAttemptRepo, error) {
+	app := do.MustInvoke[core.App](i)
+	return NewMessageAttemptRepo(app), nil
+}
+
+// messageAttemptColumns 查询发送历史时的列
+var messageAttemptColumns = []string{
+	"id",
+	"message_id",
+	"destination_type",
+	"target",
+	"trigger",
+	"duration_ms",
+	"http_status",
+	"retcode",
+	"error",
+	"bot",
+	"created_at",
+}
+
+type CreateMessageAttemptIn struct {
+	MessageID       string
+	DestinationType message_box_enum.DestinationType
+	Target          string
+	Trigger         string
+	Duration        time.Duration
+	HTTPStatus      int
+	Retcode         int
+	Error           string
+	Bot             string
+}
+
+func (m *MessageAttemptRepo) Create(ctx context.Context, in CreateMessageAttemptIn) (*model.MessageAttemptModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageAttemptRepo.Create")
+	defer span.End()
+
+	collection, err := m.app.FindCachedCollectionByNameOrId(messageAttemptCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先创建 MessageAttemptModel
+	attempt := &model.MessageAttemptModel{
+		MessageID:       in.MessageID,
+		DestinationType: in.DestinationType,
+		Target:          in.Target,
+		Trigger:         in.Trigger,
+		DurationMS:      in.Duration.Milliseconds(),
+		HTTPStatus:      in.HTTPStatus,
+		Retcode:         in.Retcode,
+		Error:           in.Error,
+		Bot:             in.Bot,
+		CreatedAt:       time.Now().Unix(),
+	}
+
+	// 使用 MessageAttemptModel 的值构建 Record，ID 由 PocketBase 生成
+	record := core.NewRecord(collection)
+	record.Load(map[string]any{
+		"message_id":       attempt.MessageID,
+		"destination_type": attempt.DestinationType.Val(),
+		"target":           attempt.Target,
+		"trigger":          attempt.Trigger,
+		"duration_ms":      attempt.DurationMS,
+		"http_status":      attempt.HTTPStatus,
+		"retcode":          attempt.Retcode,
+		"error":            attempt.Error,
+		"bot":              attempt.Bot,
+		"created_at":       attempt.CreatedAt,
+	})
+	if err := m.app.SaveWithContext(ctx, record); err != nil {
+		return nil, err
+	}
+
+	attempt.ID = record.Id
+	return attempt, nil
+}
+
+// ListByMessageID 按时间顺序查询消息的发送历史
+func (m *MessageAttemptRepo) ListByMessageID(ctx context.Context, messageID string) ([]*model.MessageAttemptModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageAttemptRepo.ListByMessageID")
+	defer span.End()
+
+	attempts := make([]*model.MessageAttemptModel, 0)
+	if err := m.app.DB().Select(messageAttemptColumns...).
+		From("message_attempt").
+		Where(dbx.HashExp{"message_id": messageID}).
+		OrderBy("created_at ASC", "created ASC").
+		WithContext(ctx).
+		All(&attempts); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// LatestByDestination 查询目的地最近一次成功或失败的发送记录，不存在时返回 nil
+func (m *MessageAttemptRepo) LatestByDestination(ctx context.Context, destinationType message_box_enum.DestinationType, failed bool) (*model.MessageAttemptModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageAttemptRepo.LatestByDestination")
+	defer span.End()
+
+	where := dbx.And(dbx.HashExp{"destination_type": destinationType.Val()}, dbx.HashExp{"error": ""})
+	if failed {
+		where = dbx.And(dbx.HashExp{"destination_type": destinationType.Val()}, dbx.Not(dbx.HashExp{"error": ""}))
+	}
+
+	attempt := &model.MessageAttemptModel{}
+	err := m.app.DB().Select(messageAttemptColumns...).
+		From("message_attempt").
+		Where(where).
+		OrderBy("created_at DESC", "created DESC").
+		Limit(1).
+		WithContext(ctx).
+		One(attempt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
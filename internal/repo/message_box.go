@@ -2,138 +2,500 @@ package repo
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"message-pocket/internal/constants/message_box_enum"
 	"message-pocket/internal/define/model"
+	"message-pocket/internal/encryption"
+	"message-pocket/internal/tracing"
+	"strings"
 	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
 	"github.com/samber/do/v2"
+	"github.com/samber/lo"
 )
 
 type IMessageBoxRepo interface {
 	Create(ctx context.Context, in CreateMessageIn) (*model.MessageBoxModel, error)
 	ListFailedBefore(ctx context.Context, t time.Time) ([]*model.MessageBoxModel, error)
-	UpdateByID(ctx context.Context, messageID int32, data map[string]any) error
+	ListByStatus(ctx context.Context, status message_box_enum.StatusType) ([]*model.MessageBoxModel, error)
+	List(ctx context.Context, in ListMessagesIn) ([]*model.MessageBoxModel, int64, error)
+	GetByID(ctx context.Context, messageID string) (*model.MessageBoxModel, error)
+	UpdateByID(ctx context.Context, messageID string, data map[string]any) error
+	UpdateByIDs(ctx context.Context, messageIDs []string, data map[string]any) error
+	CountByStatus(ctx context.Context) ([]*model.MessageCountModel, error)
+	RotateEncryption(ctx context.Context, limit int) (int, error)
 }
 
+// MessageBoxRepo message_box 是 PocketBase 集合，写入通过 Record 保存以触发 hooks 和 realtime
+// 启用加密时 message 和 source_request 在写入时加密、读取时解密，调用方拿到的始终是明文
 type MessageBoxRepo struct {
-	db dbx.Builder
+	app     core.App
+	keyring *encryption.Keyring
 }
 
-func NewMessageBoxRepo(db dbx.Builder) *MessageBoxRepo {
+// ErrDuplicateMessage 同一来源已保存过相同幂等键的消息
+var ErrDuplicateMessage = errors.New("message with the same idempotency key already exists")
+
+// ErrKeywordSearchEncrypted 启用加密后 message 列为密文，无法在数据库中按关键字搜索
+var ErrKeywordSearchEncrypted = errors.New("keyword search is unavailable while encryption is enabled")
+
+// messageBoxCollection 消息集合名称
+const messageBoxCollection = "message_box"
+
+func NewMessageBoxRepo(app core.App, keyring *encryption.Keyring) *MessageBoxRepo {
 	return &MessageBoxRepo{
-		db: db,
+		app:     app,
+		keyring: keyring,
 	}
 }
 
 func ProvideMessageBoxRepo(i do.Injector) (*MessageBoxRepo, error) {
-	db := do.MustInvoke[dbx.Builder](i)
-	return NewMessageBoxRepo(db), nil
+	app := do.MustInvoke[core.App](i)
+	keyring := do.MustInvoke[*encryption.Keyring](i)
+	return NewMessageBoxRepo(app, keyring), nil
+}
+
+// messageBoxColumns 查询消息时的列
+var messageBoxColumns = []string{
+	"id",
+	"biz_id",
+	"status",
+	"message",
+	"source_request",
+	"source_type",
+	"destination_type",
+	"severity",
+	"expires_at",
+	"created_at",
+	"last_sent_at",
+	"last_error",
+	"attempts",
+	"receipt",
+	"target",
+	"operation",
+	"operated_by",
+	"operated_at",
+	"key_id",
+	"data_key",
+	"trace_id",
+	"span_id",
+	"trace_flags",
+	"idempotency_key",
 }
 
 type CreateMessageIn struct {
-	BizID           string `db:"biz_id"`
+	BizID string `db:"biz_id"`
+	// Status 初始状态，为空时默认发送中(Pending)
+	Status          message_box_enum.StatusType
 	Message         string
 	SourceRequest   string
 	SourceType      message_box_enum.SourceType
 	DestinationType message_box_enum.DestinationType
+	Severity        message_box_enum.Severity
+	// ExpiresAt 超过该时间仍未发送成功则不再重试，零值表示不过期
+	ExpiresAt time.Time
+	// Target 指定的发送目标，为空时使用目的地的默认配置
+	Target string
+	// TraceID、SpanID、TraceFlags 创建消息时的 trace，用于关联重试
+	TraceID    string
+	SpanID     string
+	TraceFlags string
+	// IdempotencyKey 幂等键，同一来源只保存一条，为空表示不去重
+	IdempotencyKey string
 }
 
 func (m *MessageBoxRepo) Create(ctx context.Context, in CreateMessageIn) (*model.MessageBoxModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.Create")
+	defer span.End()
+
+	collection, err := m.app.FindCachedCollectionByNameOrId(messageBoxCollection)
+	if err != nil {
+		return nil, err
+	}
+
 	// 先创建 MessageBoxModel
 	createdAt := time.Now().Unix()
-	createdAtStr := fmt.Sprintf("%d", createdAt)
+	var expiresAt int64
+	if !in.ExpiresAt.IsZero() {
+		expiresAt = in.ExpiresAt.Unix()
+	}
+	status := in.Status
+	if status == 0 {
+		status = message_box_enum.Pending
+	}
 
 	messageBox := &model.MessageBoxModel{
-		ID:              0, // 将在插入后更新
-		Status:          1, // 默认状态为发送中(Pending)
+		BizID:           in.BizID,
+		Status:          status.Val(),
 		Message:         in.Message,
 		SourceRequest:   in.SourceRequest,
 		SourceType:      in.SourceType,
 		DestinationType: in.DestinationType,
-		CreatedAt:       createdAtStr,
-		LastedSentAt:    "",
-	}
-
-	// 使用 MessageBoxModel 的值构建 SQL
-	result, err := m.db.NewQuery(`
-		INSERT INTO message_box (
-			biz_id,
-			status,
-			message,
-			source_request,
-			source_type,
-			destination_type,
-			created_at
-		) VALUES (
-			{:biz_id},
-			{:status},
-			{:message},
-			{:source_request},
-			{:source_type},
-			{:destination_type},
-			{:created_at}
-		)
-	`).
-		Bind(map[string]any{
-			"biz_id":           in.BizID,
-			"status":           messageBox.Status,
-			"message":          messageBox.Message,
-			"source_request":   messageBox.SourceRequest,
-			"source_type":      messageBox.SourceType.Val(),
-			"destination_type": messageBox.DestinationType.Val(),
-			"created_at":       createdAt,
-		}).
-		WithContext(ctx).
-		Execute()
-	if err != nil {
-		return nil, err
+		Severity:        in.Severity,
+		ExpiresAt:       expiresAt,
+		CreatedAt:       fmt.Sprintf("%d", createdAt),
+		Target:          in.Target,
+		TraceID:         in.TraceID,
+		SpanID:          in.SpanID,
+		TraceFlags:      in.TraceFlags,
+		IdempotencyKey:  in.IdempotencyKey,
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
+	// 加密后的内容只写入 Record，返回的 MessageBoxModel 保持明文
+	storedMessage, storedSourceRequest := messageBox.Message, messageBox.SourceRequest
+	if m.keyring.Enabled() {
+		// 缺少密钥字段时密文无法解密，拒绝写入
+		if collection.Fields.GetByName("key_id") == nil || collection.Fields.GetByName("data_key") == nil {
+			return nil, errors.New("message_box is missing encryption fields, apply pending migrations first")
+		}
+		keyID, dataKey, ciphertexts, err := m.keyring.Seal(messageBox.Message, messageBox.SourceRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt message: %w", err)
+		}
+		messageBox.KeyID, messageBox.DataKey = keyID, dataKey
+		storedMessage, storedSourceRequest = ciphertexts[0], ciphertexts[1]
+	}
+
+	// 使用 MessageBoxModel 的值构建 Record，ID 由 PocketBase 生成
+	record := core.NewRecord(collection)
+	record.Load(map[string]any{
+		"biz_id":           messageBox.BizID,
+		"status":           messageBox.Status,
+		"message":          storedMessage,
+		"source_request":   storedSourceRequest,
+		"source_type":      messageBox.SourceType.Val(),
+		"destination_type": messageBox.DestinationType.Val(),
+		"severity":         messageBox.Severity.Val(),
+		"expires_at":       messageBox.ExpiresAt,
+		"created_at":       createdAt,
+		"target":           messageBox.Target,
+		"key_id":           messageBox.KeyID,
+		"data_key":         messageBox.DataKey,
+		"trace_id":         messageBox.TraceID,
+		"span_id":          messageBox.SpanID,
+		"trace_flags":      messageBox.TraceFlags,
+		"idempotency_key":  messageBox.IdempotencyKey,
+	})
+	if err := m.app.SaveWithContext(ctx, record); err != nil {
+		// 唯一索引保证并发的重复投递也只保存一条，PocketBase 将唯一索引冲突转换为字段的校验错误
+		var validationErrs validation.Errors
+		if errors.As(err, &validationErrs) {
+			if fieldErr, ok := validationErrs["idempotency_key"].(validation.Error); ok && fieldErr.Code() == "validation_not_unique" {
+				return nil, ErrDuplicateMessage
+			}
+		}
 		return nil, err
 	}
 
-	// 更新 ID
-	messageBox.ID = int32(id)
+	messageBox.ID = record.Id
 	return messageBox, nil
 }
 
 func (m *MessageBoxRepo) ListFailedBefore(ctx context.Context, t time.Time) ([]*model.MessageBoxModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.ListFailedBefore")
+	defer span.End()
+
+	messages := make([]*model.MessageBoxModel, 0)
+	// 查询状态为发送中(Pending)且创建时间早于指定时间的消息，严重级别高的优先重试
+	if err := m.app.DB().Select(messageBoxColumns...).
+		From("message_box").
+		Where(dbx.HashExp{"status": message_box_enum.Pending.Val()}). // 发送中状态
+		AndWhere(dbx.NewExp("created_at < {:created_at}", dbx.Params{"created_at": t.Unix()})).
+		OrderBy("severity DESC", "created_at", "id").
+		WithContext(ctx).
+		All(&messages); err != nil {
+		return nil, err
+	}
+
+	if err := m.decrypt(messages...); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ListByStatus 按状态查询消息，按创建时间升序
+func (m *MessageBoxRepo) ListByStatus(ctx context.Context, status message_box_enum.StatusType) ([]*model.MessageBoxModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.ListByStatus")
+	defer span.End()
+
 	messages := make([]*model.MessageBoxModel, 0)
-	// 查询状态为发送中(Pending)且创建时间早于指定时间的消息
-	if err := m.db.NewQuery(`
-			SELECT
-				id,
-				status,
-				message,
-				source_request,
-				source_type,
-				destination_type,
-				created_at,
-				last_sent_at
-			FROM message_box
-			WHERE status = {:status}
-			AND created_at < {:created_at}
-		`).
-		Bind(map[string]any{
-			"status":     message_box_enum.Pending.Val(), // 发送中状态
-			"created_at": t.Unix(),
-		}).
+	if err := m.app.DB().Select(messageBoxColumns...).
+		From("message_box").
+		Where(dbx.HashExp{"status": status.Val()}).
+		OrderBy("created_at", "id").
 		WithContext(ctx).
 		All(&messages); err != nil {
 		return nil, err
 	}
 
+	if err := m.decrypt(messages...); err != nil {
+		return nil, err
+	}
 	return messages, nil
 }
 
-func (m *MessageBoxRepo) UpdateByID(ctx context.Context, messageID int32, data map[string]any) error {
-	// 如果是发送成功状态，更新最后发送时间
-	_, err := m.db.Update("message_box", data, dbx.NewExp("id = {:id}", dbx.Params{"id": messageID})).
+// ListMessagesIn 分页查询消息的条件，零值表示不过滤
+type ListMessagesIn struct {
+	Statuses        []message_box_enum.StatusType
+	SourceType      message_box_enum.SourceType
+	DestinationType message_box_enum.DestinationType
+	BizID           string
+	TraceID         string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	// Keyword 在消息内容中模糊搜索
+	Keyword string
+	// Sort 排序列，前缀 - 表示降序，为空时按创建时间降序
+	Sort    string
+	Page    int
+	PerPage int
+}
+
+// messageBoxSortColumns 允许排序的列
+var messageBoxSortColumns = map[string]struct{}{
+	"id":           {},
+	"created_at":   {},
+	"last_sent_at": {},
+	"severity":     {},
+	"status":       {},
+	"attempts":     {},
+}
+
+// IsMessageBoxSortColumn 判断是否允许按该列排序
+func IsMessageBoxSortColumn(column string) bool {
+	_, ok := messageBoxSortColumns[column]
+	return ok
+}
+
+// List 分页查询消息，不返回 source_request，返回符合条件的总数
+func (m *MessageBoxRepo) List(ctx context.Context, in ListMessagesIn) ([]*model.MessageBoxModel, int64, error) {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.List")
+	defer span.End()
+
+	if in.Keyword != "" && m.keyring.Enabled() {
+		return nil, 0, ErrKeywordSearchEncrypted
+	}
+
+	where := dbx.And(listMessagesConditions(in)...)
+
+	var total int64
+	if err := m.app.DB().Select("COUNT(*)").
+		From("message_box").
+		Where(where).
 		WithContext(ctx).
-		Execute()
-	return err
+		Row(&total); err != nil {
+		return nil, 0, err
+	}
+
+	orderBy := "created_at DESC"
+	if column := strings.TrimPrefix(in.Sort, "-"); column != "" {
+		if !IsMessageBoxSortColumn(column) {
+			return nil, 0, fmt.Errorf("unsupported sort column: %s", column)
+		}
+		orderBy = column + " ASC"
+		if strings.HasPrefix(in.Sort, "-") {
+			orderBy = column + " DESC"
+		}
+	}
+
+	columns := lo.Without(messageBoxColumns, "source_request")
+	messages := make([]*model.MessageBoxModel, 0)
+	if err := m.app.DB().Select(columns...).
+		From("message_box").
+		Where(where).
+		OrderBy(orderBy, "id DESC").
+		Offset(int64((in.Page - 1) * in.PerPage)).
+		Limit(int64(in.PerPage)).
+		WithContext(ctx).
+		All(&messages); err != nil {
+		return nil, 0, err
+	}
+
+	if err := m.decrypt(messages...); err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+func listMessagesConditions(in ListMessagesIn) []dbx.Expression {
+	conditions := make([]dbx.Expression, 0)
+	if len(in.Statuses) > 0 {
+		statuses := make([]any, 0, len(in.Statuses))
+		for _, status := range in.Statuses {
+			statuses = append(statuses, status.Val())
+		}
+		conditions = append(conditions, dbx.In("status", statuses...))
+	}
+	if in.SourceType != 0 {
+		conditions = append(conditions, dbx.HashExp{"source_type": in.SourceType.Val()})
+	}
+	if in.DestinationType != 0 {
+		conditions = append(conditions, dbx.HashExp{"destination_type": in.DestinationType.Val()})
+	}
+	if in.BizID != "" {
+		conditions = append(conditions, dbx.HashExp{"biz_id": in.BizID})
+	}
+	if in.TraceID != "" {
+		conditions = append(conditions, dbx.HashExp{"trace_id": in.TraceID})
+	}
+	if !in.CreatedFrom.IsZero() {
+		conditions = append(conditions, dbx.NewExp("created_at >= {:created_from}", dbx.Params{"created_from": in.CreatedFrom.Unix()}))
+	}
+	if !in.CreatedTo.IsZero() {
+		conditions = append(conditions, dbx.NewExp("created_at < {:created_to}", dbx.Params{"created_to": in.CreatedTo.Unix()}))
+	}
+	// 只能匹配明文消息，启用加密时由 List 拒绝
+	if in.Keyword != "" {
+		conditions = append(conditions, dbx.Like("message", in.Keyword))
+	}
+	return conditions
+}
+
+// GetByID 按 ID 查询消息，不存在时返回 nil
+func (m *MessageBoxRepo) GetByID(ctx context.Context, messageID string) (*model.MessageBoxModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.GetByID")
+	defer span.End()
+
+	messageBox := &model.MessageBoxModel{}
+	err := m.app.DB().Select(messageBoxColumns...).
+		From("message_box").
+		Where(dbx.HashExp{"id": messageID}).
+		WithContext(ctx).
+		One(messageBox)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.decrypt(messageBox); err != nil {
+		return nil, err
+	}
+	return messageBox, nil
+}
+
+// decrypt 解密消息内容和原始请求，列表查询未选择 source_request 时保持为空
+func (m *MessageBoxRepo) decrypt(messages ...*model.MessageBoxModel) error {
+	for _, message := range messages {
+		if message.KeyID == "" {
+			continue
+		}
+		plaintexts, err := m.keyring.Open(message.KeyID, message.DataKey, message.Message, message.SourceRequest)
+		if err != nil {
+			return fmt.Errorf("message %s: %w", message.ID, err)
+		}
+		message.Message, message.SourceRequest = plaintexts[0], plaintexts[1]
+	}
+	return nil
+}
+
+// RotateEncryption 将一批未使用当前主密钥的消息迁移到当前主密钥，返回处理的条数
+// 明文消息会被加密，使用旧主密钥的消息只重新加密数据密钥
+func (m *MessageBoxRepo) RotateEncryption(ctx context.Context, limit int) (int, error) {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.RotateEncryption")
+	defer span.End()
+
+	if !m.keyring.Enabled() {
+		return 0, errors.New("encryption is not enabled, set encryption.active_key first")
+	}
+
+	records, err := m.app.FindRecordsByFilter(
+		messageBoxCollection,
+		"key_id != {:key_id}",
+		"created_at",
+		limit,
+		0,
+		dbx.Params{"key_id": m.keyring.ActiveKeyID()},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, record := range records {
+		if keyID := record.GetString("key_id"); keyID != "" {
+			newKeyID, dataKey, err := m.keyring.Rewrap(keyID, record.GetString("data_key"))
+			if err != nil {
+				return 0, fmt.Errorf("message %s: %w", record.Id, err)
+			}
+			record.Set("key_id", newKeyID)
+			record.Set("data_key", dataKey)
+		} else {
+			keyID, dataKey, ciphertexts, err := m.keyring.Seal(record.GetString("message"), record.GetString("source_request"))
+			if err != nil {
+				return 0, fmt.Errorf("message %s: %w", record.Id, err)
+			}
+			record.Set("key_id", keyID)
+			record.Set("data_key", dataKey)
+			record.Set("message", ciphertexts[0])
+			record.Set("source_request", ciphertexts[1])
+		}
+		if err := m.app.SaveWithContext(ctx, record); err != nil {
+			return 0, fmt.Errorf("message %s: %w", record.Id, err)
+		}
+	}
+
+	return len(records), nil
+}
+
+// CountByStatus 按状态和目的地统计消息数
+func (m *MessageBoxRepo) CountByStatus(ctx context.Context) ([]*model.MessageCountModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.CountByStatus")
+	defer span.End()
+
+	counts := make([]*model.MessageCountModel, 0)
+	if err := m.app.DB().Select("status", "destination_type", "COUNT(*) AS count").
+		From("message_box").
+		GroupBy("status", "destination_type").
+		OrderBy("status", "destination_type").
+		WithContext(ctx).
+		All(&counts); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// UpdateByID 更新消息字段，数值字段支持 PocketBase 的 "field+" 写法做增量更新
+func (m *MessageBoxRepo) UpdateByID(ctx context.Context, messageID string, data map[string]any) error {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.UpdateByID")
+	defer span.End()
+
+	record, err := m.app.FindRecordById(messageBoxCollection, messageID)
+	if err != nil {
+		return err
+	}
+	for key, value := range data {
+		record.Set(key, value)
+	}
+	return m.app.SaveWithContext(ctx, record)
+}
+
+func (m *MessageBoxRepo) UpdateByIDs(ctx context.Context, messageIDs []string, data map[string]any) error {
+	ctx, span := tracing.Start(ctx, "MessageBoxRepo.UpdateByIDs")
+	defer span.End()
+
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	return m.app.RunInTransaction(func(txApp core.App) error {
+		records, err := txApp.FindRecordsByIds(messageBoxCollection, messageIDs)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			for key, value := range data {
+				record.Set(key, value)
+			}
+			if err := txApp.SaveWithContext(ctx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
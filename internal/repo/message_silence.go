@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"context"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/tracing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/samber/do/v2"
+)
+
+type IMessageSilenceRepo interface {
+	Create(ctx context.Context, in CreateSilenceIn) (*model.MessageSilenceModel, error)
+	ListActiveAt(ctx context.Context, t time.Time) ([]*model.MessageSilenceModel, error)
+	ListNotExpired(ctx context.Context, t time.Time) ([]*model.MessageSilenceModel, error)
+	ExpireByID(ctx context.Context, silenceID int32, t time.Time) (bool, error)
+}
+
+type MessageSilenceRepo struct {
+	db dbx.Builder
+}
+
+func NewMessageSilenceRepo(db dbx.Builder) *MessageSilenceRepo {
+	return &MessageSilenceRepo{
+		db: db,
+	}
+}
+
+func ProvideMessageSilenceRepo(i do.Injector) (*MessageSilenceRepo, error) {
+	db := do.MustInvoke[dbx.Builder](i)
+	return NewMessageSilenceRepo(db), nil
+}
+
+type CreateSilenceIn struct {
+	Matchers  []model.SilenceMatcher
+	Comment   string
+	CreatedBy string
+	StartsAt  time.Time
+	EndsAt    time.Time
+}
+
+func (m *MessageSilenceRepo) Create(ctx context.Context, in CreateSilenceIn) (*model.MessageSilenceModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageSilenceRepo.Create")
+	defer span.End()
+
+	// 先创建 MessageSilenceModel
+	silence := &model.MessageSilenceModel{
+		ID:        0, // 将在插入后更新
+		Matchers:  types.JSONArray[model.SilenceMatcher](in.Matchers),
+		Comment:   in.Comment,
+		CreatedBy: in.CreatedBy,
+		StartsAt:  in.StartsAt.Unix(),
+		EndsAt:    in.EndsAt.Unix(),
+		CreatedAt: time.Now().Unix(),
+	}
+
+	result, err := m.db.NewQuery(`
+		INSERT INTO message_silence (
+			matchers,
+			comment,
+			created_by,
+			starts_at,
+			ends_at,
+			created_at
+		) VALUES (
+			{:matchers},
+			{:comment},
+			{:created_by},
+			{:starts_at},
+			{:ends_at},
+			{:created_at}
+		)
+	`).
+		Bind(map[string]any{
+			"matchers":   silence.Matchers,
+			"comment":    silence.Comment,
+			"created_by": silence.CreatedBy,
+			"starts_at":  silence.StartsAt,
+			"ends_at":    silence.EndsAt,
+			"created_at": silence.CreatedAt,
+		}).
+		WithContext(ctx).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	silence.ID = int32(id)
+	return silence, nil
+}
+
+// ListActiveAt 查询在指定时间生效的静默规则
+func (m *MessageSilenceRepo) ListActiveAt(ctx context.Context, t time.Time) ([]*model.MessageSilenceModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageSilenceRepo.ListActiveAt")
+	defer span.End()
+
+	silences := make([]*model.MessageSilenceModel, 0)
+	if err := m.db.NewQuery(`
+			SELECT
+				id,
+				matchers,
+				comment,
+				created_by,
+				starts_at,
+				ends_at,
+				created_at
+			FROM message_silence
+			WHERE starts_at <= {:t}
+			AND ends_at > {:t}
+		`).
+		Bind(map[string]any{
+			"t": t.Unix(),
+		}).
+		WithContext(ctx).
+		All(&silences); err != nil {
+		return nil, err
+	}
+
+	return silences, nil
+}
+
+// ListNotExpired 查询生效中以及尚未开始的静默规则
+func (m *MessageSilenceRepo) ListNotExpired(ctx context.Context, t time.Time) ([]*model.MessageSilenceModel, error) {
+	ctx, span := tracing.Start(ctx, "MessageSilenceRepo.ListNotExpired")
+	defer span.End()
+
+	silences := make([]*model.MessageSilenceModel, 0)
+	if err := m.db.NewQuery(`
+			SELECT
+				id,
+				matchers,
+				comment,
+				created_by,
+				starts_at,
+				ends_at,
+				created_at
+			FROM message_silence
+			WHERE ends_at > {:t}
+			ORDER BY starts_at
+		`).
+		Bind(map[string]any{
+			"t": t.Unix(),
+		}).
+		WithContext(ctx).
+		All(&silences); err != nil {
+		return nil, err
+	}
+
+	return silences, nil
+}
+
+// ExpireByID 将静默规则的结束时间提前到指定时间，使其立即失效，返回静默规则是否存在
+func (m *MessageSilenceRepo) ExpireByID(ctx context.Context, silenceID int32, t time.Time) (bool, error) {
+	ctx, span := tracing.Start(ctx, "MessageSilenceRepo.ExpireByID")
+	defer span.End()
+
+	result, err := m.db.Update("message_silence", dbx.Params{"ends_at": t.Unix()}, dbx.NewExp("id = {:id}", dbx.Params{"id": silenceID})).
+		WithContext(ctx).
+		Execute()
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
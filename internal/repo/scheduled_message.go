@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/constants/scheduled_message_enum"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/tracing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/samber/do/v2"
+)
+
+type IScheduledMessageRepo interface {
+	Create(ctx context.Context, in CreateScheduledMessageIn) (*model.ScheduledMessageModel, error)
+	ListDue(ctx context.Context, t time.Time) ([]*model.ScheduledMessageModel, error)
+	ListByStatus(ctx context.Context, status scheduled_message_enum.StatusType) ([]*model.ScheduledMessageModel, error)
+	GetByID(ctx context.Context, scheduleID int32) (*model.ScheduledMessageModel, error)
+	UpdateByID(ctx context.Context, scheduleID int32, data map[string]any) error
+	Claim(ctx context.Context, scheduleID int32, nextRunAt int64, data map[string]any) (bool, error)
+}
+
+type ScheduledMessageRepo struct {
+	db dbx.Builder
+}
+
+func NewScheduledMessageRepo(db dbx.Builder) *ScheduledMessageRepo {
+	return &ScheduledMessageRepo{
+		db: db,
+	}
+}
+
+func ProvideScheduledMessageRepo(i do.Injector) (*ScheduledMessageRepo, error) {
+	db := do.MustInvoke[dbx.Builder](i)
+	return NewScheduledMessageRepo(db), nil
+}
+
+type CreateScheduledMessageIn struct {
+	Message      string
+	Destinations []string
+	Severity     message_box_enum.Severity
+	CronExpr     string
+	Timezone     string
+	ExpireAfter  time.Duration
+	NextRunAt    time.Time
+	CreatedBy    string
+}
+
+const scheduledMessageColumns = `
+				id,
+				message,
+				destinations,
+				severity,
+				cron_expr,
+				timezone,
+				expire_after,
+				status,
+				next_run_at,
+				last_run_at,
+				created_by,
+				created_at`
+
+func (m *ScheduledMessageRepo) Create(ctx context.Context, in CreateScheduledMessageIn) (*model.ScheduledMessageModel, error) {
+	ctx, span := tracing.Start(ctx, "ScheduledMessageRepo.Create")
+	defer span.End()
+
+	// 先创建 ScheduledMessageModel
+	schedule := &model.ScheduledMessageModel{
+		ID:           0, // 将在插入后更新
+		Message:      in.Message,
+		Destinations: types.JSONArray[string](in.Destinations),
+		Severity:     in.Severity,
+		CronExpr:     in.CronExpr,
+		Timezone:     in.Timezone,
+		ExpireAfter:  int64(in.ExpireAfter.Seconds()),
+		Status:       scheduled_message_enum.Active,
+		NextRunAt:    in.NextRunAt.Unix(),
+		CreatedBy:    in.CreatedBy,
+		CreatedAt:    time.Now().Unix(),
+	}
+
+	result, err := m.db.NewQuery(`
+		INSERT INTO scheduled_message (
+			message,
+			destinations,
+			severity,
+			cron_expr,
+			timezone,
+			expire_after,
+			status,
+			next_run_at,
+			created_by,
+			created_at
+		) VALUES (
+			{:message},
+			{:destinations},
+			{:severity},
+			{:cron_expr},
+			{:timezone},
+			{:expire_after},
+			{:status},
+			{:next_run_at},
+			{:created_by},
+			{:created_at}
+		)
+	`).
+		Bind(map[string]any{
+			"message":      schedule.Message,
+			"destinations": schedule.Destinations,
+			"severity":     schedule.Severity.Val(),
+			"cron_expr":    schedule.CronExpr,
+			"timezone":     schedule.Timezone,
+			"expire_after": schedule.ExpireAfter,
+			"status":       schedule.Status.Val(),
+			"next_run_at":  schedule.NextRunAt,
+			"created_by":   schedule.CreatedBy,
+			"created_at":   schedule.CreatedAt,
+		}).
+		WithContext(ctx).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.ID = int32(id)
+	return schedule, nil
+}
+
+// ListDue 查询到期需要触发的定时消息
+func (m *ScheduledMessageRepo) ListDue(ctx context.Context, t time.Time) ([]*model.ScheduledMessageModel, error) {
+	ctx, span := tracing.Start(ctx, "ScheduledMessageRepo.ListDue")
+	defer span.End()
+
+	schedules := make([]*model.ScheduledMessageModel, 0)
+	if err := m.db.NewQuery(`
+			SELECT` + scheduledMessageColumns + `
+			FROM scheduled_message
+			WHERE status = {:status}
+			AND next_run_at <= {:t}
+			ORDER BY next_run_at, id
+		`).
+		Bind(map[string]any{
+			"status": scheduled_message_enum.Active.Val(),
+			"t":      t.Unix(),
+		}).
+		WithContext(ctx).
+		All(&schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// GetByID 按 ID 查询定时消息，不存在时返回 nil
+func (m *ScheduledMessageRepo) GetByID(ctx context.Context, scheduleID int32) (*model.ScheduledMessageModel, error) {
+	ctx, span := tracing.Start(ctx, "ScheduledMessageRepo.GetByID")
+	defer span.End()
+
+	schedule := &model.ScheduledMessageModel{}
+	err := m.db.NewQuery(`
+			SELECT` + scheduledMessageColumns + `
+			FROM scheduled_message
+			WHERE id = {:id}
+		`).
+		Bind(map[string]any{
+			"id": scheduleID,
+		}).
+		WithContext(ctx).
+		One(schedule)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// ListByStatus 按状态查询定时消息，按下次触发时间升序
+func (m *ScheduledMessageRepo) ListByStatus(ctx context.Context, status scheduled_message_enum.StatusType) ([]*model.ScheduledMessageModel, error) {
+	ctx, span := tracing.Start(ctx, "ScheduledMessageRepo.ListByStatus")
+	defer span.End()
+
+	schedules := make([]*model.ScheduledMessageModel, 0)
+	if err := m.db.NewQuery(`
+			SELECT` + scheduledMessageColumns + `
+			FROM scheduled_message
+			WHERE status = {:status}
+			ORDER BY next_run_at, id
+		`).
+		Bind(map[string]any{
+			"status": status.Val(),
+		}).
+		WithContext(ctx).
+		All(&schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (m *ScheduledMessageRepo) UpdateByID(ctx context.Context, scheduleID int32, data map[string]any) error {
+	ctx, span := tracing.Start(ctx, "ScheduledMessageRepo.UpdateByID")
+	defer span.End()
+
+	_, err := m.db.Update("scheduled_message", data, dbx.NewExp("id = {:id}", dbx.Params{"id": scheduleID})).
+		WithContext(ctx).
+		Execute()
+	return err
+}
+
+// Claim 定时消息仍为生效中且下次触发时间仍为 nextRunAt 时更新，返回是否更新成功。
+// 发送前认领本次触发，执行时间重叠的定时任务只有一个能认领成功
+func (m *ScheduledMessageRepo) Claim(ctx context.Context, scheduleID int32, nextRunAt int64, data map[string]any) (bool, error) {
+	ctx, span := tracing.Start(ctx, "ScheduledMessageRepo.Claim")
+	defer span.End()
+
+	result, err := m.db.Update("scheduled_message", data, dbx.HashExp{
+		"id":          scheduleID,
+		"status":      scheduled_message_enum.Active.Val(),
+		"next_run_at": nextRunAt,
+	}).
+		WithContext(ctx).
+		Execute()
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
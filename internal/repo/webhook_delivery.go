@@ -0,0 +1,101 @@
+package repo
+
+import (
+	"context"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/tracing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/samber/do/v2"
+)
+
+type IWebhookDeliveryRepo interface {
+	Remember(ctx context.Context, sourceType message_box_enum.SourceType, deliveryID string, ttl time.Duration) (bool, error)
+	Forget(ctx context.Context, sourceType message_box_enum.SourceType, deliveryID string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// WebhookDeliveryRepo webhook_delivery 记录来源 webhook 已处理的投递 ID，用于丢弃重复投递
+type WebhookDeliveryRepo struct {
+	db dbx.Builder
+}
+
+func NewWebhookDeliveryRepo(db dbx.Builder) *WebhookDeliveryRepo {
+	return &WebhookDeliveryRepo{
+		db: db,
+	}
+}
+
+func ProvideWebhookDeliveryRepo(i do.Injector) (*WebhookDeliveryRepo, error) {
+	db := do.MustInvoke[dbx.Builder](i)
+	return NewWebhookDeliveryRepo(db), nil
+}
+
+// Remember 记录投递 ID，返回是否为首次投递；已过期但尚未清理的记录视为不存在
+func (m *WebhookDeliveryRepo) Remember(ctx context.Context, sourceType message_box_enum.SourceType, deliveryID string, ttl time.Duration) (bool, error) {
+	ctx, span := tracing.Start(ctx, "WebhookDeliveryRepo.Remember")
+	defer span.End()
+
+	now := time.Now()
+	result, err := m.db.NewQuery(`
+		INSERT INTO webhook_delivery (
+			source_type,
+			delivery_id,
+			created_at,
+			expires_at
+		) VALUES (
+			{:source_type},
+			{:delivery_id},
+			{:created_at},
+			{:expires_at}
+		)
+		ON CONFLICT (source_type, delivery_id) DO UPDATE SET
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+		WHERE webhook_delivery.expires_at <= {:created_at}
+	`).
+		Bind(map[string]any{
+			"source_type": sourceType.Val(),
+			"delivery_id": deliveryID,
+			"created_at":  now.Unix(),
+			"expires_at":  now.Add(ttl).Unix(),
+		}).
+		WithContext(ctx).
+		Execute()
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// Forget 删除投递 ID，处理失败时调用，来源重新投递时可以再次处理
+func (m *WebhookDeliveryRepo) Forget(ctx context.Context, sourceType message_box_enum.SourceType, deliveryID string) error {
+	ctx, span := tracing.Start(ctx, "WebhookDeliveryRepo.Forget")
+	defer span.End()
+
+	_, err := m.db.Delete("webhook_delivery", dbx.HashExp{
+		"source_type": sourceType.Val(),
+		"delivery_id": deliveryID,
+	}).WithContext(ctx).Execute()
+	return err
+}
+
+// DeleteExpired 删除已过期的投递 ID，返回删除的条数
+func (m *WebhookDeliveryRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "WebhookDeliveryRepo.DeleteExpired")
+	defer span.End()
+
+	result, err := m.db.Delete("webhook_delivery", dbx.NewExp("expires_at <= {:now}", dbx.Params{"now": now.Unix()})).
+		WithContext(ctx).
+		Execute()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"message-pocket/internal/config"
+	"sync"
+	"time"
+
+	"github.com/samber/do/v2"
+)
+
+// defaultAuthLockout 未配置时的认证失败锁定规则
+var defaultAuthLockout = config.AuthLockoutConfig{
+	MaxFailures: 10,
+	Window:      10 * time.Minute,
+	Duration:    15 * time.Minute,
+}
+
+// authFailure 单个 IP 的认证失败记录
+type authFailure struct {
+	count       int
+	firstAt     time.Time
+	lockedUntil time.Time
+}
+
+// AuthLockoutService 按 IP 统计认证失败次数，超过阈值后在锁定时长内拒绝该 IP 的请求，防止暴力猜测令牌
+type AuthLockoutService struct {
+	mu       sync.Mutex
+	rule     config.AuthLockoutConfig
+	failures map[string]*authFailure
+	prunedAt time.Time
+}
+
+// NewAuthLockoutService 创建认证失败锁定服务实例
+func NewAuthLockoutService(cfg *config.Config) *AuthLockoutService {
+	return &AuthLockoutService{
+		rule:     withAuthLockoutDefaults(cfg.ServerConfig.AuthLockout),
+		failures: make(map[string]*authFailure),
+	}
+}
+
+func ProvideAuthLockoutService(i do.Injector) (*AuthLockoutService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	return NewAuthLockoutService(cfg), nil
+}
+
+// Reload 使用新配置的锁定规则，已锁定的 IP 保持原锁定时长
+func (s *AuthLockoutService) Reload(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rule = withAuthLockoutDefaults(cfg.ServerConfig.AuthLockout)
+}
+
+// withAuthLockoutDefaults 零值字段使用默认值
+func withAuthLockoutDefaults(rule config.AuthLockoutConfig) config.AuthLockoutConfig {
+	if rule.MaxFailures == 0 {
+		rule.MaxFailures = defaultAuthLockout.MaxFailures
+	}
+	if rule.Window <= 0 {
+		rule.Window = defaultAuthLockout.Window
+	}
+	if rule.Duration <= 0 {
+		rule.Duration = defaultAuthLockout.Duration
+	}
+	return rule
+}
+
+// LockedFor 返回 IP 剩余的锁定时长，未锁定时返回 0
+func (s *AuthLockoutService) LockedFor(ip string, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failure, ok := s.failures[ip]
+	if !ok || !now.Before(failure.lockedUntil) {
+		return 0
+	}
+	return failure.lockedUntil.Sub(now)
+}
+
+// RecordFailure 记录一次认证失败，达到阈值时锁定该 IP 并返回 true
+func (s *AuthLockoutService) RecordFailure(ip string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rule.MaxFailures < 0 {
+		return false
+	}
+
+	s.prune(now)
+
+	failure, ok := s.failures[ip]
+	if !ok || now.Sub(failure.firstAt) > s.rule.Window {
+		failure = &authFailure{firstAt: now}
+		s.failures[ip] = failure
+	}
+	failure.count++
+	if failure.count < s.rule.MaxFailures {
+		return false
+	}
+
+	// 锁定后重新计数，解锁后再次失败需要重新累计
+	failure.count = 0
+	failure.firstAt = now
+	failure.lockedUntil = now.Add(s.rule.Duration)
+	return true
+}
+
+// Reset 认证成功后清除 IP 的失败记录
+func (s *AuthLockoutService) Reset(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, ip)
+}
+
+// prune 每个时间窗口清理一次过期的失败记录，调用方需持有锁
+func (s *AuthLockoutService) prune(now time.Time) {
+	if now.Sub(s.prunedAt) < s.rule.Window {
+		return
+	}
+	s.prunedAt = now
+
+	for ip, failure := range s.failures {
+		if now.Sub(failure.firstAt) > s.rule.Window && !now.Before(failure.lockedUntil) {
+			delete(s.failures, ip)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/metrics"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/do/v2"
+)
+
+// ErrCircuitOpen 目的地熔断中，消息需要延后发送
+var ErrCircuitOpen = errors.New("circuit open, message deferred")
+
+// 熔断状态
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// defaultCircuitBreaker 未配置或配置为零值时使用的熔断规则
+var defaultCircuitBreaker = config.CircuitBreakerConfig{
+	FailureThreshold:  5,
+	OpenTimeout:       time.Minute,
+	HalfOpenMaxProbes: 1,
+	SuccessThreshold:  1,
+}
+
+// circuitKey 熔断按目的地和发送目标分别统计
+type circuitKey struct {
+	destination message_box_enum.DestinationType
+	target      string
+}
+
+// circuit 单个目的地和发送目标的熔断状态
+type circuit struct {
+	state string
+	// failures 关闭状态下的连续失败次数
+	failures int
+	// successes 半开状态下的连续成功次数
+	successes int
+	// probes 半开状态下进行中的试探发送数
+	probes   int
+	openedAt time.Time
+}
+
+// CircuitStatus 熔断器状态，OpenedAt 为最近一次熔断的时间（Unix 秒）
+type CircuitStatus struct {
+	Target              string `json:"target"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	OpenedAt            int64  `json:"opened_at,omitempty"`
+}
+
+// CircuitBreakerService 按目的地和发送目标熔断，连续失败达到阈值后直接延后发送，不再调用目的地
+type CircuitBreakerService struct {
+	rules   atomic.Pointer[map[message_box_enum.DestinationType]config.CircuitBreakerConfig]
+	metrics *metrics.Metrics
+
+	mu       sync.Mutex
+	circuits map[circuitKey]*circuit
+}
+
+// NewCircuitBreakerService 创建熔断服务实例
+func NewCircuitBreakerService(cfg *config.Config, metrics *metrics.Metrics) *CircuitBreakerService {
+	s := &CircuitBreakerService{
+		metrics:  metrics,
+		circuits: make(map[circuitKey]*circuit),
+	}
+	rules := parseCircuitBreakers(cfg)
+	s.rules.Store(&rules)
+	return s
+}
+
+func ProvideCircuitBreakerService(i do.Injector) (*CircuitBreakerService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	metrics := do.MustInvoke[*metrics.Metrics](i)
+	return NewCircuitBreakerService(cfg, metrics), nil
+}
+
+// Reload 使用新配置的熔断规则，已有的熔断状态保留
+func (s *CircuitBreakerService) Reload(cfg *config.Config) {
+	rules := parseCircuitBreakers(cfg)
+	s.rules.Store(&rules)
+}
+
+// parseCircuitBreakers 合并默认规则与配置的规则，忽略未知的目的地
+func parseCircuitBreakers(cfg *config.Config) map[message_box_enum.DestinationType]config.CircuitBreakerConfig {
+	rules := make(map[message_box_enum.DestinationType]config.CircuitBreakerConfig)
+	for _, destination := range message_box_enum.DestinationTypes() {
+		rules[destination] = defaultCircuitBreaker
+	}
+	for name, rule := range cfg.CircuitBreaker {
+		if destination, ok := message_box_enum.ParseDestinationType(name); ok {
+			rules[destination] = withCircuitDefaults(rule)
+		}
+	}
+	return rules
+}
+
+// withCircuitDefaults 零值字段使用默认值
+func withCircuitDefaults(rule config.CircuitBreakerConfig) config.CircuitBreakerConfig {
+	if rule.FailureThreshold == 0 {
+		rule.FailureThreshold = defaultCircuitBreaker.FailureThreshold
+	}
+	if rule.OpenTimeout <= 0 {
+		rule.OpenTimeout = defaultCircuitBreaker.OpenTimeout
+	}
+	if rule.HalfOpenMaxProbes <= 0 {
+		rule.HalfOpenMaxProbes = defaultCircuitBreaker.HalfOpenMaxProbes
+	}
+	if rule.SuccessThreshold <= 0 {
+		rule.SuccessThreshold = defaultCircuitBreaker.SuccessThreshold
+	}
+	return rule
+}
+
+// Allow 判断能否向目的地发送，熔断中返回 ErrCircuitOpen。
+// 允许发送时返回 done，调用目的地后必须以发送结果调用一次；限流延后等未实际调用的情况传入对应错误，不计入失败
+func (s *CircuitBreakerService) Allow(ctx context.Context, destination message_box_enum.DestinationType, target string) (func(err error), error) {
+	rule, ok := (*s.rules.Load())[destination]
+	if !ok || rule.FailureThreshold < 0 {
+		return func(error) {}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := circuitKey{destination: destination, target: target}
+	c := s.getCircuit(key)
+
+	if c.state == CircuitOpen {
+		if time.Since(c.openedAt) < rule.OpenTimeout {
+			return nil, ErrCircuitOpen
+		}
+		s.transition(ctx, key, c, CircuitHalfOpen)
+	}
+
+	probe := false
+	if c.state == CircuitHalfOpen {
+		if c.probes >= rule.HalfOpenMaxProbes {
+			return nil, ErrCircuitOpen
+		}
+		c.probes++
+		probe = true
+	}
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			s.done(ctx, key, rule, probe, err)
+		})
+	}, nil
+}
+
+// IsOpen 判断发送目标是否熔断且未到进入半开的时间，只读取状态，不占用半开的试探名额
+func (s *CircuitBreakerService) IsOpen(destination message_box_enum.DestinationType, target string) bool {
+	rule, ok := (*s.rules.Load())[destination]
+	if !ok || rule.FailureThreshold < 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.circuits[circuitKey{destination: destination, target: target}]
+	return ok && c.state == CircuitOpen && time.Since(c.openedAt) < rule.OpenTimeout
+}
+
+// done 记录一次发送结果并更新熔断状态
+func (s *CircuitBreakerService) done(ctx context.Context, key circuitKey, rule config.CircuitBreakerConfig, probe bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.getCircuit(key)
+	if probe && c.probes > 0 {
+		c.probes--
+	}
+
+	// 未实际调用目的地
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if err == nil {
+		switch c.state {
+		case CircuitClosed:
+			c.failures = 0
+		case CircuitHalfOpen:
+			c.successes++
+			if c.successes >= rule.SuccessThreshold {
+				s.transition(ctx, key, c, CircuitClosed)
+			}
+		}
+		return
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		c.failures++
+		if c.failures >= rule.FailureThreshold {
+			s.transition(ctx, key, c, CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		// 试探失败重新熔断
+		s.transition(ctx, key, c, CircuitOpen)
+	}
+}
+
+// getCircuit 获取或创建熔断器，调用方需持有锁
+func (s *CircuitBreakerService) getCircuit(key circuitKey) *circuit {
+	c, ok := s.circuits[key]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		s.circuits[key] = c
+		s.metrics.CircuitTransition(key.destination.String(), key.target, "", CircuitClosed)
+	}
+	return c
+}
+
+// transition 切换熔断状态并记录日志和指标，调用方需持有锁
+func (s *CircuitBreakerService) transition(ctx context.Context, key circuitKey, c *circuit, to string) {
+	from := c.state
+	c.state = to
+	c.successes = 0
+	switch to {
+	case CircuitOpen:
+		c.openedAt = time.Now()
+		c.probes = 0
+	case CircuitClosed:
+		c.failures = 0
+	}
+
+	slog.WarnContext(ctx, "Circuit state changed",
+		"destination", key.destination.String(),
+		"target", key.target,
+		"from", from,
+		"to", to,
+		"consecutive_failures", c.failures)
+	s.metrics.CircuitTransition(key.destination.String(), key.target, from, to)
+}
+
+// States 查询目的地各发送目标的熔断状态，按发送目标排序
+func (s *CircuitBreakerService) States(destination message_box_enum.DestinationType) []*CircuitStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]*CircuitStatus, 0)
+	for key, c := range s.circuits {
+		if key.destination != destination {
+			continue
+		}
+		status := &CircuitStatus{
+			Target:              key.target,
+			State:               c.state,
+			ConsecutiveFailures: c.failures,
+		}
+		if !c.openedAt.IsZero() {
+			status.OpenedAt = c.openedAt.Unix()
+		}
+		states = append(states, status)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Target < states[j].Target
+	})
+	return states
+}
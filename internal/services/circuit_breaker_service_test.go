@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/metrics"
+	"testing"
+	"time"
+)
+
+const testTarget = "123456"
+
+var errTestSend = errors.New("napcat unavailable")
+
+// newTestCircuitBreaker 创建只配置 qq-group 规则的熔断服务
+func newTestCircuitBreaker(rule config.CircuitBreakerConfig) *CircuitBreakerService {
+	return NewCircuitBreakerService(&config.Config{
+		CircuitBreaker: map[string]config.CircuitBreakerConfig{
+			message_box_enum.DestinationQQGroup.String(): rule,
+		},
+	}, metrics.NewMetrics(nil))
+}
+
+// elapseOpenTimeout 将熔断时间提前，模拟已经过 open_timeout
+func elapseOpenTimeout(s *CircuitBreakerService, target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.circuits[circuitKey{destination: message_box_enum.DestinationQQGroup, target: target}]; ok {
+		c.openedAt = c.openedAt.Add(-24 * time.Hour)
+	}
+}
+
+// circuitState 发送目标的熔断状态，没有熔断器时为空
+func circuitState(s *CircuitBreakerService, target string) string {
+	for _, state := range s.States(message_box_enum.DestinationQQGroup) {
+		if state.Target == target {
+			return state.State
+		}
+	}
+	return ""
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	type step struct {
+		// elapse 本次发送前经过 open_timeout
+		elapse bool
+		// sendErr 允许发送时的发送结果
+		sendErr     error
+		wantAllowed bool
+		wantState   string
+	}
+
+	tests := []struct {
+		name  string
+		rule  config.CircuitBreakerConfig
+		steps []step
+	}{
+		{
+			name: "opens after consecutive failures",
+			rule: config.CircuitBreakerConfig{FailureThreshold: 3},
+			steps: []step{
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitOpen},
+				{wantAllowed: false, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			rule: config.CircuitBreakerConfig{FailureThreshold: 3},
+			steps: []step{
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitClosed},
+				{wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "rate limited and cancelled sends are not failures",
+			rule: config.CircuitBreakerConfig{FailureThreshold: 2},
+			steps: []step{
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: ErrRateLimited, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: context.Canceled, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "successful probe closes the circuit",
+			rule: config.CircuitBreakerConfig{FailureThreshold: 1},
+			steps: []step{
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitOpen},
+				{wantAllowed: false, wantState: CircuitOpen},
+				{elapse: true, wantAllowed: true, wantState: CircuitClosed},
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "failed probe reopens the circuit",
+			rule: config.CircuitBreakerConfig{FailureThreshold: 1},
+			steps: []step{
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitOpen},
+				{elapse: true, sendErr: errTestSend, wantAllowed: true, wantState: CircuitOpen},
+				{wantAllowed: false, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "closes after enough successful probes",
+			rule: config.CircuitBreakerConfig{FailureThreshold: 1, SuccessThreshold: 2},
+			steps: []step{
+				{sendErr: errTestSend, wantAllowed: true, wantState: CircuitOpen},
+				{elapse: true, wantAllowed: true, wantState: CircuitHalfOpen},
+				{wantAllowed: true, wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "negative threshold never opens",
+			rule: config.CircuitBreakerConfig{FailureThreshold: -1},
+			steps: []step{
+				{sendErr: errTestSend, wantAllowed: true},
+				{sendErr: errTestSend, wantAllowed: true},
+				{sendErr: errTestSend, wantAllowed: true},
+				{sendErr: errTestSend, wantAllowed: true},
+				{sendErr: errTestSend, wantAllowed: true},
+				{sendErr: errTestSend, wantAllowed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestCircuitBreaker(tt.rule)
+			ctx := context.Background()
+			for idx, step := range tt.steps {
+				if step.elapse {
+					elapseOpenTimeout(s, testTarget)
+				}
+				done, err := s.Allow(ctx, message_box_enum.DestinationQQGroup, testTarget)
+				if allowed := err == nil; allowed != step.wantAllowed {
+					t.Fatalf("step %d: Allow() error = %v, want allowed %v", idx, err, step.wantAllowed)
+				}
+				if err == nil {
+					done(step.sendErr)
+				} else if !errors.Is(err, ErrCircuitOpen) {
+					t.Fatalf("step %d: Allow() error = %v, want ErrCircuitOpen", idx, err)
+				}
+				if state := circuitState(s, testTarget); state != step.wantState {
+					t.Fatalf("step %d: state = %q, want %q", idx, state, step.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxProbes  int
+		wantProbes int
+	}{
+		{name: "default allows one probe", maxProbes: 0, wantProbes: 1},
+		{name: "configured probes", maxProbes: 3, wantProbes: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 1, HalfOpenMaxProbes: tt.maxProbes})
+			ctx := context.Background()
+
+			done, err := s.Allow(ctx, message_box_enum.DestinationQQGroup, testTarget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			done(errTestSend)
+			elapseOpenTimeout(s, testTarget)
+
+			// 试探发送未结束前，超出名额的发送继续延后
+			probes := 0
+			for range tt.wantProbes + 2 {
+				if _, err := s.Allow(ctx, message_box_enum.DestinationQQGroup, testTarget); err == nil {
+					probes++
+				}
+			}
+			if probes != tt.wantProbes {
+				t.Errorf("probes = %d, want %d", probes, tt.wantProbes)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerIsOpen(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		elapse   bool
+		target   string
+		want     bool
+	}{
+		{name: "closed", failures: 1, target: testTarget, want: false},
+		{name: "open", failures: 2, target: testTarget, want: true},
+		{name: "open timeout elapsed", failures: 2, elapse: true, target: testTarget, want: false},
+		{name: "other target", failures: 2, target: "654321", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 2})
+			for range tt.failures {
+				done, err := s.Allow(context.Background(), message_box_enum.DestinationQQGroup, testTarget)
+				if err != nil {
+					t.Fatal(err)
+				}
+				done(errTestSend)
+			}
+			if tt.elapse {
+				elapseOpenTimeout(s, testTarget)
+			}
+
+			if got := s.IsOpen(message_box_enum.DestinationQQGroup, tt.target); got != tt.want {
+				t.Errorf("IsOpen() = %v, want %v", got, tt.want)
+			}
+			// IsOpen 只读取状态，不会使熔断进入半开
+			if tt.elapse && circuitState(s, testTarget) != CircuitOpen {
+				t.Errorf("state = %q after IsOpen(), want %q", circuitState(s, testTarget), CircuitOpen)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"log/slog"
+	"message-pocket/internal/config"
+	"message-pocket/internal/encryption"
+	"message-pocket/internal/redact"
+	"reflect"
+	"sync"
+
+	"github.com/samber/do/v2"
+)
+
+// ConfigReloadService 校验热加载的配置并通知各服务使用新配置
+type ConfigReloadService struct {
+	ipAccess       *IPAccessService
+	mute           *MuteService
+	severity       *SeverityService
+	rateLimit      *RateLimitService
+	circuitBreaker *CircuitBreakerService
+	watchdog       *WatchdogService
+	authLockout    *AuthLockoutService
+	webhook        *WebhookDeliveryService
+
+	mu sync.Mutex
+	// started 启动时的配置，用于判断需要重启才能生效的配置是否变化
+	started *config.Config
+}
+
+func NewConfigReloadService(
+	cfg *config.Config,
+	ipAccess *IPAccessService,
+	mute *MuteService,
+	severity *SeverityService,
+	rateLimit *RateLimitService,
+	circuitBreaker *CircuitBreakerService,
+	watchdog *WatchdogService,
+	authLockout *AuthLockoutService,
+	webhook *WebhookDeliveryService,
+) *ConfigReloadService {
+	return &ConfigReloadService{
+		ipAccess:       ipAccess,
+		mute:           mute,
+		severity:       severity,
+		rateLimit:      rateLimit,
+		circuitBreaker: circuitBreaker,
+		watchdog:       watchdog,
+		authLockout:    authLockout,
+		webhook:        webhook,
+		started:        cfg,
+	}
+}
+
+func ProvideConfigReloadService(i do.Injector) (*ConfigReloadService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	ipAccess, err := do.Invoke[*IPAccessService](i)
+	if err != nil {
+		return nil, err
+	}
+	mute, err := do.Invoke[*MuteService](i)
+	if err != nil {
+		return nil, err
+	}
+	severity, err := do.Invoke[*SeverityService](i)
+	if err != nil {
+		return nil, err
+	}
+	watchdog, err := do.Invoke[*WatchdogService](i)
+	if err != nil {
+		return nil, err
+	}
+	rateLimit := do.MustInvoke[*RateLimitService](i)
+	circuitBreaker := do.MustInvoke[*CircuitBreakerService](i)
+	authLockout := do.MustInvoke[*AuthLockoutService](i)
+	webhook := do.MustInvoke[*WebhookDeliveryService](i)
+	return NewConfigReloadService(cfg, ipAccess, mute, severity, rateLimit, circuitBreaker, watchdog, authLockout, webhook), nil
+}
+
+// ValidateConfig 校验配置，返回包含全部错误及其配置路径的 config.ValidationError。
+// 启动、config validate 和热加载使用同一套校验
+func ValidateConfig(cfg *config.Config) error {
+	v := &config.Validator{}
+	v.Join(config.Validate(cfg))
+
+	_, err := parseMuteSchedules(cfg)
+	v.Join(err)
+	_, err = parseSeverityRules(cfg)
+	v.Join(err)
+	_, err = parseIPAccessRules(cfg)
+	v.Join(err)
+	_, _, err = parseWatchdog(cfg)
+	v.Join(err)
+	// 加密密钥和脱敏规则的错误已带有配置路径
+	_, err = encryption.NewKeyring(cfg.Encryption)
+	v.Join(err)
+	_, err = redact.NewRedactors(cfg.Redaction)
+	v.Join(err)
+
+	return v.Err()
+}
+
+// Validate 热加载前校验新配置，校验失败时保留原配置
+func (s *ConfigReloadService) Validate(cfg *config.Config) error {
+	return ValidateConfig(cfg)
+}
+
+// Apply 通知各服务使用已通过校验的新配置。
+// 加密、脱敏和链路追踪在启动时初始化，变化时只记录日志，需要重启才能生效
+func (s *ConfigReloadService) Apply(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, reload := range []struct {
+		name string
+		fn   func(cfg *config.Config) error
+	}{
+		{"ip_access", s.ipAccess.Reload},
+		{"mute", s.mute.Reload},
+		{"severity", s.severity.Reload},
+		{"watchdog", s.watchdog.Reload},
+	} {
+		// 已经过 Validate 校验，这里出错说明校验与解析不一致
+		if err := reload.fn(cfg); err != nil {
+			slog.Error("Failed to reload config section", "section", reload.name, "err", err)
+		}
+	}
+	s.rateLimit.Reload(cfg)
+	s.circuitBreaker.Reload(cfg)
+	s.authLockout.Reload(cfg)
+	s.webhook.Reload(cfg)
+
+	for _, section := range []struct {
+		name     string
+		old, new any
+	}{
+		{"encryption", s.started.Encryption, cfg.Encryption},
+		{"redaction", s.started.Redaction, cfg.Redaction},
+		{"tracing", s.started.Tracing, cfg.Tracing},
+	} {
+		if !reflect.DeepEqual(section.old, section.new) {
+			slog.Warn("Config section changed, restart required to take effect", "section", section.name)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"message-pocket/internal/define/dtos"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/repo"
+	"message-pocket/internal/services/logic"
+	"time"
+
+	"github.com/samber/do/v2"
+)
+
+// EODeploymentService 关联同一次 EdgeOne 部署的各个事件，跟踪状态变化和耗时
+type EODeploymentService struct {
+	deploymentRepo repo.IEODeploymentRepo
+}
+
+// DeploymentTrackResult 部署状态跟踪结果
+type DeploymentTrackResult struct {
+	Deployment *model.EODeploymentModel
+	// Previous 同一分支上一次已结束的部署，仅在本次部署结束时查询
+	Previous *model.EODeploymentModel
+}
+
+func NewEODeploymentService(deploymentRepo repo.IEODeploymentRepo) *EODeploymentService {
+	return &EODeploymentService{
+		deploymentRepo: deploymentRepo,
+	}
+}
+
+func ProvideEODeploymentService(i do.Injector) (*EODeploymentService, error) {
+	deploymentRepo := do.MustInvoke[repo.IEODeploymentRepo](i)
+	return NewEODeploymentService(deploymentRepo), nil
+}
+
+// Track 记录事件带来的部署状态变化，缺少项目或部署 ID 的事件不跟踪，返回 nil
+func (s *EODeploymentService) Track(ctx context.Context, event *dtos.EOEventRequest) (*DeploymentTrackResult, error) {
+	if event.ProjectID == "" || event.DeploymentID == "" {
+		return nil, nil
+	}
+
+	at := time.Now()
+	if t, err := time.Parse(time.RFC3339, event.Timestamp); err == nil {
+		at = t
+	}
+
+	deployment, err := s.getOrCreate(ctx, event, at)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]any{
+		"last_event_type": event.EventType,
+		"updated_at":      time.Now().Unix(),
+	}
+	deployment.LastEventType = event.EventType
+	if event.ProjectName != "" {
+		data["project_name"] = event.ProjectName
+		deployment.ProjectName = event.ProjectName
+	}
+	if event.RepoBranch != "" {
+		data["repo_branch"] = event.RepoBranch
+		deployment.RepoBranch = event.RepoBranch
+	}
+	// 事件可能乱序到达，部署开始时间取最早的事件时间
+	if at.Unix() < deployment.CreatedAt {
+		data["created_at"] = at.Unix()
+		deployment.CreatedAt = at.Unix()
+	}
+
+	// 各阶段时间只记录第一次，避免重复投递覆盖
+	setOnce := func(column string, field *int64) {
+		if *field == 0 {
+			*field = at.Unix()
+			data[column] = *field
+		}
+	}
+	switch event.EventType {
+	case "build.started":
+		setOnce("build_started_at", &deployment.BuildStartedAt)
+	case "build.succeeded", "build.failed":
+		setOnce("build_finished_at", &deployment.BuildFinishedAt)
+	case "deployment.in_progress":
+		setOnce("deploy_started_at", &deployment.DeployStartedAt)
+	}
+
+	result := &DeploymentTrackResult{Deployment: deployment}
+	if deploymentResult := logic.GetDeploymentResult(event.EventType); deploymentResult != "" {
+		setOnce("finished_at", &deployment.FinishedAt)
+
+		previous, err := s.deploymentRepo.GetLastFinished(ctx, deployment.ProjectID, deployment.RepoBranch, deployment.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get previous deployment: %w", err)
+		}
+		result.Previous = previous
+
+		// 只有成功会中断连续失败，取消的部署沿用上一次的连续失败次数
+		var failStreak int32
+		switch deploymentResult {
+		case logic.DeploymentResultFailed:
+			failStreak = 1
+			if previous != nil {
+				failStreak = previous.FailStreak + 1
+			}
+		case logic.DeploymentResultCancelled:
+			if previous != nil {
+				failStreak = previous.FailStreak
+			}
+		}
+		deployment.Result = deploymentResult
+		deployment.FailStreak = failStreak
+		data["result"] = deploymentResult
+		data["fail_streak"] = failStreak
+	}
+
+	if err := s.deploymentRepo.UpdateByID(ctx, deployment.ID, data); err != nil {
+		return nil, fmt.Errorf("failed to update deployment: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *EODeploymentService) getOrCreate(ctx context.Context, event *dtos.EOEventRequest, at time.Time) (*model.EODeploymentModel, error) {
+	deployment, err := s.deploymentRepo.GetByDeploymentID(ctx, event.ProjectID, event.DeploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	if deployment != nil {
+		return deployment, nil
+	}
+
+	deployment, err = s.deploymentRepo.Create(ctx, repo.CreateEODeploymentIn{
+		ProjectID:     event.ProjectID,
+		DeploymentID:  event.DeploymentID,
+		ProjectName:   event.ProjectName,
+		RepoBranch:    event.RepoBranch,
+		LastEventType: event.EventType,
+		CreatedAt:     at,
+	})
+	if err == nil {
+		return deployment, nil
+	}
+
+	// 并发的同一部署事件可能已先创建，重新查询一次
+	deployment, getErr := s.deploymentRepo.GetByDeploymentID(ctx, event.ProjectID, event.DeploymentID)
+	if getErr != nil || deployment == nil {
+		return nil, fmt.Errorf("failed to create deployment: %w", err)
+	}
+	return deployment, nil
+}
@@ -8,39 +8,152 @@ import (
 	"message-pocket/internal/constants/message_box_enum"
 	"message-pocket/internal/define/dtos"
 	"message-pocket/internal/services/logic"
+	"message-pocket/internal/tracing"
+	"strings"
+	"time"
 
 	"github.com/samber/do/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type EOService struct {
-	messageBoxService *MessageBoxService
+	messageBoxService      *MessageBoxService
+	severityService        *SeverityService
+	eoDeploymentService    *EODeploymentService
+	webhookDeliveryService *WebhookDeliveryService
 }
 
 func NewEOService(
 	messageBoxService *MessageBoxService,
+	severityService *SeverityService,
+	eoDeploymentService *EODeploymentService,
+	webhookDeliveryService *WebhookDeliveryService,
 ) *EOService {
 	return &EOService{
-		messageBoxService: messageBoxService,
+		messageBoxService:      messageBoxService,
+		severityService:        severityService,
+		eoDeploymentService:    eoDeploymentService,
+		webhookDeliveryService: webhookDeliveryService,
 	}
 }
 
 func ProvideEOService(i do.Injector) (*EOService, error) {
 	messageBoxService := do.MustInvoke[*MessageBoxService](i)
-	return NewEOService(messageBoxService), nil
+	severityService := do.MustInvoke[*SeverityService](i)
+	eoDeploymentService := do.MustInvoke[*EODeploymentService](i)
+	webhookDeliveryService := do.MustInvoke[*WebhookDeliveryService](i)
+	return NewEOService(messageBoxService, severityService, eoDeploymentService, webhookDeliveryService), nil
+}
+
+// eoDeliveryID EdgeOne 不提供投递 ID，同一部署的同一事件视为同一次投递。
+// 这只是推测，不写入消息的幂等键：投递记录过期后同一部署再次发生的同类事件（如重新部署后再次失败）仍需通知
+func eoDeliveryID(event *dtos.EOEventRequest) string {
+	if event.DeploymentID == "" {
+		return ""
+	}
+	return event.DeploymentID + "/" + event.EventType
 }
 
 func (s *EOService) EOWebhookEventHandle(ctx context.Context, event *dtos.EOEventRequest) error {
+	ctx, span := tracing.Start(ctx, "EOService.EOWebhookEventHandle", trace.WithAttributes(
+		attribute.String("eo.event_type", event.EventType),
+		attribute.String("eo.deployment_id", event.DeploymentID),
+	))
+	err := s.eoWebhookEventHandle(ctx, event)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *EOService) eoWebhookEventHandle(ctx context.Context, event *dtos.EOEventRequest) error {
+	// timestamp 不在签名范围内，只能拒绝原样重放的旧请求；缺失或无法解析时不检查
+	if t, err := time.Parse(time.RFC3339, event.Timestamp); err == nil {
+		if err := s.webhookDeliveryService.CheckTimestamp(t, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	deliveryID := eoDeliveryID(event)
+	if deliveryID == "" {
+		_, err := s.processEvent(ctx, event)
+		return err
+	}
+
+	// 重复投递在跟踪部署状态之前丢弃，避免重复统计；记录失败时仍处理，宁可重复通知也不丢失
+	first, err := s.webhookDeliveryService.Remember(ctx, message_box_enum.SourceTypeEO, deliveryID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to remember EO delivery", "err", err, "delivery_id", deliveryID)
+	} else if !first {
+		slog.InfoContext(ctx, "Dropped duplicate EO event", "delivery_id", deliveryID)
+		return nil
+	}
+
+	saved, err := s.processEvent(ctx, event)
+	if err != nil && !saved {
+		// 消息未保存时来源重新投递需要再次处理，不能当作重复丢弃；已保存的由重试任务继续发送
+		s.webhookDeliveryService.Forget(ctx, message_box_enum.SourceTypeEO, deliveryID)
+	}
+	return err
+}
+
+// processEvent 跟踪部署状态并保存、发送通知，返回消息是否已保存
+func (s *EOService) processEvent(ctx context.Context, event *dtos.EOEventRequest) (bool, error) {
+	severity := s.severityService.Resolve(message_box_enum.SourceTypeEO, event.EventType)
+
+	// 跟踪部署状态，失败不影响通知发送
+	track, err := s.eoDeploymentService.Track(ctx, event)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to track EO deployment", "err", err, "deployment_id", event.DeploymentID)
+	}
+
+	message := s.renderMessage(ctx, event, severity, track)
+
+	requestStr, err := json.Marshal(event)
+	if err != nil {
+		return false, fmt.Errorf("marshal event to json: %w", err)
+	}
+
+	// 使用 MessageBoxService 保存并发送消息
+	messageBox, err := s.messageBoxService.SaveAndSendMessage(ctx, SaveMessageRequest{
+		BizID:           event.DeploymentID,
+		Message:         message,
+		SourceRequest:   string(requestStr),
+		SourceType:      message_box_enum.SourceTypeEO,
+		DestinationType: message_box_enum.DestinationQQGroup,
+		Severity:        severity,
+		Labels: map[string]string{
+			"event_type":   event.EventType,
+			"project_id":   event.ProjectID,
+			"project_name": event.ProjectName,
+			"repo_branch":  event.RepoBranch,
+		},
+	})
+	if err != nil {
+		// 发送失败时消息已保存，返回的 messageBox 不为空
+		return messageBox != nil, fmt.Errorf("failed to save and send message: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Successfully sent notification for EO event", "event_type", event.EventType)
+	return true, nil
+}
+
+// renderMessage 构建通知消息，部署结束时附加耗时和历史结果的摘要
+func (s *EOService) renderMessage(ctx context.Context, event *dtos.EOEventRequest, severity message_box_enum.Severity, track *DeploymentTrackResult) string {
+	_, span := tracing.Start(ctx, "EOService.renderMessage")
+	defer span.End()
+
 	// 获取消息类型标签
 	messageTypeLabel := logic.GetMessageTypeLabel(event.EventType)
 
 	// 构建详细消息
-	message := fmt.Sprintf(`🚀 EdgeOne 部署事件
+	message := fmt.Sprintf(`%s EdgeOne 部署事件
 📋 事件类型: %s
 📁 项目名称: %s
 🌿 代码分支: %s
 🆔 项目ID: %s
 🆔 部署ID: %s
 ⏰ 时间: %s`,
+		logic.GetSeverityEmoji(severity),
 		messageTypeLabel,
 		event.ProjectName,
 		event.RepoBranch,
@@ -48,24 +161,47 @@ func (s *EOService) EOWebhookEventHandle(ctx context.Context, event *dtos.EOEven
 		event.DeploymentID,
 		event.Timestamp,
 	)
+	if summary := buildDeploymentSummary(track); summary != "" {
+		message += "\n" + summary
+	}
 
-	requestStr, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("marshal event to json: %w", err)
+	return message
+}
+
+// buildDeploymentSummary 为结束的部署构建耗时和历史结果的摘要
+func buildDeploymentSummary(track *DeploymentTrackResult) string {
+	if track == nil || track.Deployment.Result == "" {
+		return ""
 	}
+	deployment := track.Deployment
 
-	// 使用 MessageBoxService 保存并发送消息
-	_, err = s.messageBoxService.SaveAndSendMessage(ctx, SaveMessageRequest{
-		BizID:           event.DeploymentID,
-		Message:         message,
-		SourceRequest:   string(requestStr),
-		SourceType:      message_box_enum.SourceTypeEO,
-		DestinationType: message_box_enum.DestinationQQGroup,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to save and send message: %w", err)
+	lines := make([]string, 0, 5)
+	formatDuration := func(from, to int64) string {
+		return (time.Duration(to-from) * time.Second).String()
+	}
+	if deployment.BuildStartedAt > 0 && deployment.BuildFinishedAt >= deployment.BuildStartedAt {
+		lines = append(lines, "⏱️ 构建耗时: "+formatDuration(deployment.BuildStartedAt, deployment.BuildFinishedAt))
+	}
+	deployStartedAt := max(deployment.DeployStartedAt, deployment.BuildFinishedAt)
+	if deployStartedAt > 0 && deployment.FinishedAt >= deployStartedAt {
+		lines = append(lines, "⏱️ 部署耗时: "+formatDuration(deployStartedAt, deployment.FinishedAt))
+	}
+	if deployment.FinishedAt > deployment.CreatedAt {
+		lines = append(lines, "⏱️ 总耗时: "+formatDuration(deployment.CreatedAt, deployment.FinishedAt))
 	}
 
-	slog.InfoContext(ctx, "Successfully sent notification for EO event", "event_type", event.EventType)
-	return nil
+	previous := track.Previous
+	if previous != nil {
+		lines = append(lines, fmt.Sprintf("📜 上次部署: %s (%s)",
+			logic.GetDeploymentResultLabel(previous.Result), previous.DeploymentID))
+
+		switch {
+		case deployment.Result == logic.DeploymentResultSucceeded && previous.FailStreak > 0:
+			lines = append(lines, fmt.Sprintf("🎉 已修复: 此前连续失败 %d 次", previous.FailStreak))
+		case deployment.Result == logic.DeploymentResultFailed && deployment.FailStreak > 1:
+			lines = append(lines, fmt.Sprintf("🔁 仍然失败: 已连续失败 %d 次", deployment.FailStreak))
+		}
+	}
+
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/repo"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/samber/do/v2"
+)
+
+// healthCheckTimeout 单项检查的超时时间，避免探针请求长时间挂起
+const healthCheckTimeout = 5 * time.Second
+
+// 就绪检查项
+const (
+	ReadyCheckDatabase   = "database"
+	ReadyCheckConfig     = "config"
+	ReadyCheckMigrations = "migrations"
+)
+
+// HealthService 就绪检查和目的地状态
+type HealthService struct {
+	app            core.App
+	attemptRepo    repo.IMessageAttemptRepo
+	napcatService  *NapCatService
+	circuitBreaker *CircuitBreakerService
+}
+
+// CheckResult 单项检查结果
+type CheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// ReadinessReport 就绪检查结果，全部检查通过时 Ready 为 true
+type ReadinessReport struct {
+	Ready  bool           `json:"ready"`
+	Checks []*CheckResult `json:"checks"`
+}
+
+// DestinationStatus 目的地最近的发送情况，时间为 Unix 秒，0 表示没有记录
+type DestinationStatus struct {
+	Destination   string `json:"destination"`
+	LastSuccessAt int64  `json:"last_success_at"`
+	LastFailureAt int64  `json:"last_failure_at"`
+	LastError     string `json:"last_error"`
+	// Circuits 各发送目标的熔断状态，没有发送过的目标不列出
+	Circuits []*CircuitStatus `json:"circuits"`
+}
+
+// NapCatStatusReport NapCat 的登录和在线状态，接口不可达时 Reachable 为 false
+type NapCatStatusReport struct {
+	Bot       string `json:"bot"`
+	Reachable bool   `json:"reachable"`
+	Online    bool   `json:"online"`
+	Good      bool   `json:"good"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StatusReport 依赖状态，NapCat 在线、状态正常且没有熔断中的目的地时 Healthy 为 true
+type StatusReport struct {
+	Healthy      bool                 `json:"healthy"`
+	Destinations []*DestinationStatus `json:"destinations"`
+	NapCat       *NapCatStatusReport  `json:"napcat"`
+}
+
+func NewHealthService(
+	app core.App,
+	attemptRepo repo.IMessageAttemptRepo,
+	napcatService *NapCatService,
+	circuitBreaker *CircuitBreakerService,
+) *HealthService {
+	return &HealthService{
+		app:            app,
+		attemptRepo:    attemptRepo,
+		napcatService:  napcatService,
+		circuitBreaker: circuitBreaker,
+	}
+}
+
+func ProvideHealthService(i do.Injector) (*HealthService, error) {
+	app := do.MustInvoke[core.App](i)
+	attemptRepo := do.MustInvoke[repo.IMessageAttemptRepo](i)
+	napcatService := do.MustInvoke[*NapCatService](i)
+	circuitBreaker := do.MustInvoke[*CircuitBreakerService](i)
+	return NewHealthService(app, attemptRepo, napcatService, circuitBreaker), nil
+}
+
+// Ready 检查数据库可访问、配置已加载且迁移已全部执行
+func (s *HealthService) Ready(ctx context.Context) *ReadinessReport {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := &ReadinessReport{Ready: true}
+	for _, check := range []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{ReadyCheckDatabase, s.checkDatabase},
+		{ReadyCheckConfig, s.checkConfig},
+		{ReadyCheckMigrations, s.checkMigrations},
+	} {
+		result := &CheckResult{Name: check.name, OK: true}
+		if err := check.fn(ctx); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+			report.Ready = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) error {
+	var one int
+	return s.app.DB().NewQuery("SELECT 1").WithContext(ctx).Row(&one)
+}
+
+func (s *HealthService) checkConfig(_ context.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("config not loaded: %w", err)
+	}
+	if cfg.NapCatConfig.URL == "" {
+		return fmt.Errorf("napcat.url is empty")
+	}
+	return nil
+}
+
+// checkMigrations 比对已注册的迁移与 _migrations 表中已执行的迁移
+func (s *HealthService) checkMigrations(ctx context.Context) error {
+	var applied []string
+	if err := s.app.DB().Select("file").
+		From(core.DefaultMigrationsTable).
+		WithContext(ctx).
+		Column(&applied); err != nil {
+		return err
+	}
+
+	appliedFiles := make(map[string]struct{}, len(applied))
+	for _, file := range applied {
+		appliedFiles[file] = struct{}{}
+	}
+
+	pending := 0
+	for _, list := range []core.MigrationsList{core.SystemMigrations, core.AppMigrations} {
+		for _, migration := range list.Items() {
+			if _, ok := appliedFiles[migration.File]; !ok {
+				pending++
+			}
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations not applied", pending)
+	}
+	return nil
+}
+
+// Status 查询各目的地最近的发送情况和 NapCat 的登录、在线状态
+func (s *HealthService) Status(ctx context.Context) (*StatusReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := &StatusReport{}
+	circuitOpen := false
+	for _, destination := range message_box_enum.DestinationTypes() {
+		status := &DestinationStatus{Destination: destination.String()}
+
+		lastSuccess, err := s.attemptRepo.LatestByDestination(ctx, destination, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query last success of %s: %w", destination, err)
+		}
+		if lastSuccess != nil {
+			status.LastSuccessAt = lastSuccess.CreatedAt
+		}
+
+		lastFailure, err := s.attemptRepo.LatestByDestination(ctx, destination, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query last failure of %s: %w", destination, err)
+		}
+		if lastFailure != nil {
+			status.LastFailureAt = lastFailure.CreatedAt
+			status.LastError = lastFailure.Error
+		}
+
+		status.Circuits = s.circuitBreaker.States(destination)
+		for _, circuit := range status.Circuits {
+			if circuit.State == CircuitOpen {
+				circuitOpen = true
+			}
+		}
+
+		report.Destinations = append(report.Destinations, status)
+	}
+
+	report.NapCat = &NapCatStatusReport{Bot: s.napcatService.Bot()}
+	napcatStatus, err := s.napcatService.GetStatus(ctx)
+	if err != nil {
+		report.NapCat.Error = err.Error()
+	} else {
+		report.NapCat.Reachable = true
+		report.NapCat.Online = napcatStatus.Online
+		report.NapCat.Good = napcatStatus.Good
+	}
+	report.Healthy = report.NapCat.Online && report.NapCat.Good && !circuitOpen
+
+	return report, nil
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"message-pocket/internal/config"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/samber/do/v2"
+)
+
+// IP 访问控制的路由分组
+const (
+	IPAccessGroupSource  = "source"
+	IPAccessGroupSend    = "send"
+	IPAccessGroupAdmin   = "admin"
+	IPAccessGroupMetrics = "metrics"
+	IPAccessGroupStatus  = "status"
+)
+
+var ipAccessGroups = []string{
+	IPAccessGroupSource,
+	IPAccessGroupSend,
+	IPAccessGroupAdmin,
+	IPAccessGroupMetrics,
+	IPAccessGroupStatus,
+}
+
+// denyByDefaultGroups 未配置规则时拒绝全部来源的分组，这些路由不经过令牌认证
+var denyByDefaultGroups = []string{
+	IPAccessGroupMetrics,
+}
+
+// ipAccessRule 解析后的路由分组规则
+type ipAccessRule struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// ipAccessRules 解析后的全部规则，热加载时整体替换
+type ipAccessRules struct {
+	trustedProxies []netip.Prefix
+	groups         map[string]*ipAccessRule
+}
+
+// IPAccessService 按路由分组的 CIDR 允许/拒绝规则
+type IPAccessService struct {
+	rules atomic.Pointer[ipAccessRules]
+}
+
+// NewIPAccessService 创建 IP 访问控制服务实例，CIDR 或导入文件有误时返回错误
+func NewIPAccessService(cfg *config.Config) (*IPAccessService, error) {
+	rules, err := parseIPAccessRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &IPAccessService{}
+	s.rules.Store(rules)
+	return s, nil
+}
+
+func ProvideIPAccessService(i do.Injector) (*IPAccessService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	return NewIPAccessService(cfg)
+}
+
+// Reload 使用新配置的规则，解析失败时保留原规则；导入文件也会重新读取
+func (s *IPAccessService) Reload(cfg *config.Config) error {
+	rules, err := parseIPAccessRules(cfg)
+	if err != nil {
+		return err
+	}
+	s.rules.Store(rules)
+	return nil
+}
+
+// parseIPAccessRules 解析 CIDR 规则并读取导入文件，返回全部规则的错误
+func parseIPAccessRules(cfg *config.Config) (*ipAccessRules, error) {
+	v := &config.Validator{}
+	trustedProxies := validatePrefixes(v, "ip_access.trusted_proxies", cfg.IPAccess.TrustedProxies)
+
+	rules := make(map[string]*ipAccessRule, len(cfg.IPAccess.Groups))
+	for _, group := range slices.Sorted(maps.Keys(cfg.IPAccess.Groups)) {
+		item := cfg.IPAccess.Groups[group]
+		path := "ip_access.groups." + group
+		if !slices.Contains(ipAccessGroups, group) {
+			v.Add(path, "unknown group %q", group)
+			continue
+		}
+
+		rule := &ipAccessRule{
+			allow: validatePrefixes(v, path+".allow", item.Allow),
+			deny:  validatePrefixes(v, path+".deny", item.Deny),
+		}
+		for idx, file := range item.AllowFiles {
+			prefixes, err := loadPrefixFile(file)
+			if err != nil {
+				v.AddError(fmt.Sprintf("%s.allow_files[%d]", path, idx), err)
+				continue
+			}
+			rule.allow = append(rule.allow, prefixes...)
+		}
+		rules[group] = rule
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	return &ipAccessRules{
+		trustedProxies: trustedProxies,
+		groups:         rules,
+	}, nil
+}
+
+// TrustsProxy 判断直连地址是否为可信代理，未配置可信代理时都视为可信
+func (s *IPAccessService) TrustsProxy(addr netip.Addr) bool {
+	trustedProxies := s.rules.Load().trustedProxies
+	if len(trustedProxies) == 0 {
+		return true
+	}
+	return containsAddr(trustedProxies, addr)
+}
+
+// Check 判断地址能否访问路由分组，拒绝时返回原因
+func (s *IPAccessService) Check(group string, addr netip.Addr) (bool, string) {
+	rule, ok := s.rules.Load().groups[group]
+	if !ok {
+		if slices.Contains(denyByDefaultGroups, group) {
+			return false, "no rules configured for this group"
+		}
+		return true, ""
+	}
+	if containsAddr(rule.deny, addr) {
+		return false, "denied by deny list"
+	}
+	if len(rule.allow) > 0 && !containsAddr(rule.allow, addr) {
+		return false, "not in allow list"
+	}
+	return true, ""
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefixes 解析 CIDR 列表，单个 IP 视为只包含该地址的网段
+func parsePrefixes(items []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		prefix, err := parsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// validatePrefixes 解析 CIDR 列表，逐项记录错误
+func validatePrefixes(v *config.Validator, path string, items []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for idx, item := range items {
+		prefix, err := parsePrefix(item)
+		if err != nil {
+			v.AddError(fmt.Sprintf("%s[%d]", path, idx), err)
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+func parsePrefix(item string) (netip.Prefix, error) {
+	item = strings.TrimSpace(item)
+	if !strings.Contains(item, "/") {
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid IP %q", item)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(item)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", item)
+	}
+	return prefix.Masked(), nil
+}
+
+// loadPrefixFile 读取 CIDR 文件，JSON 格式按 GitHub /meta 读取 hooks 字段，
+// 否则按每行一个 CIDR 读取，忽略空行和 # 注释
+func loadPrefixFile(path string) ([]netip.Prefix, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		var meta struct {
+			Hooks []string `json:"hooks"`
+		}
+		if err := json.Unmarshal(trimmed, &meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(meta.Hooks) == 0 {
+			return nil, fmt.Errorf("%s: no hooks ranges found", path)
+		}
+		prefixes, err := parsePrefixes(meta.Hooks)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return prefixes, nil
+	}
+
+	prefixes := make([]netip.Prefix, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		prefix, err := parsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, scanner.Err()
+}
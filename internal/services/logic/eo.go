@@ -1,5 +1,7 @@
 package logic
 
+import "message-pocket/internal/constants/message_box_enum"
+
 // GetMessageTypeLabel 根据事件类型获取中文标签
 func GetMessageTypeLabel(eventType string) string {
 	switch eventType {
@@ -31,3 +33,62 @@ func GetMessageTypeLabel(eventType string) string {
 		return eventType
 	}
 }
+
+// GetEventSeverity 根据事件类型获取默认严重级别，失败和回滚事件需要立即关注
+func GetEventSeverity(eventType string) message_box_enum.Severity {
+	switch eventType {
+	case "deployment.failed", "deployment.rollback", "build.failed":
+		return message_box_enum.SeverityCritical
+	case "deployment.cancelled", "project.deleted":
+		return message_box_enum.SeverityWarning
+	default:
+		return message_box_enum.SeverityInfo
+	}
+}
+
+// GetSeverityEmoji 根据严重级别获取消息标题的 emoji
+func GetSeverityEmoji(severity message_box_enum.Severity) string {
+	switch severity {
+	case message_box_enum.SeverityCritical:
+		return "🚨"
+	case message_box_enum.SeverityWarning:
+		return "⚠️"
+	default:
+		return "🚀"
+	}
+}
+
+// 部署结果
+const (
+	DeploymentResultSucceeded = "succeeded"
+	DeploymentResultFailed    = "failed"
+	DeploymentResultCancelled = "cancelled"
+)
+
+// GetDeploymentResult 根据事件类型获取部署结果，非结束事件返回空
+func GetDeploymentResult(eventType string) string {
+	switch eventType {
+	case "deployment.succeeded":
+		return DeploymentResultSucceeded
+	case "deployment.failed":
+		return DeploymentResultFailed
+	case "deployment.cancelled":
+		return DeploymentResultCancelled
+	default:
+		return ""
+	}
+}
+
+// GetDeploymentResultLabel 根据部署结果获取中文标签
+func GetDeploymentResultLabel(result string) string {
+	switch result {
+	case DeploymentResultSucceeded:
+		return "部署成功"
+	case DeploymentResultFailed:
+		return "部署失败"
+	case DeploymentResultCancelled:
+		return "部署取消"
+	default:
+		return result
+	}
+}
@@ -2,20 +2,52 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"message-pocket/internal/config"
 	"message-pocket/internal/constants/message_box_enum"
 	"message-pocket/internal/define/model"
+	"message-pocket/internal/metrics"
+	"message-pocket/internal/redact"
 	"message-pocket/internal/repo"
+	"message-pocket/internal/tracing"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/samber/do/v2"
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidArgument 请求参数不合法
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// defaultMaxAttempts 默认最多发送次数，达到后转为死信
+const defaultMaxAttempts = 20
+
+// 发送的触发方式，记录到发送历史
+const (
+	// AttemptTriggerInline 保存消息后立即发送
+	AttemptTriggerInline = "inline"
+	// AttemptTriggerRetry 重试任务发送
+	AttemptTriggerRetry = "retry"
+	// AttemptTriggerManual 人工重发、重试或测试目的地
+	AttemptTriggerManual = "manual"
 )
 
 type MessageBoxService struct {
-	napcatService  *NapCatService
-	messageBoxRepo repo.IMessageBoxRepo
+	napcatService    *NapCatService
+	rateLimitService *RateLimitService
+	circuitBreaker   *CircuitBreakerService
+	muteService      *MuteService
+	messageBoxRepo   repo.IMessageBoxRepo
+	attemptRepo      repo.IMessageAttemptRepo
+	redactors        *redact.Redactors
+	metrics          *metrics.Metrics
 }
 
 // SaveMessageRequest 保存消息的请求参数
@@ -25,22 +57,85 @@ type SaveMessageRequest struct {
 	SourceRequest   string
 	SourceType      message_box_enum.SourceType
 	DestinationType message_box_enum.DestinationType
+	// Severity 消息严重级别，为空时视为 info
+	Severity message_box_enum.Severity
+	// Labels 来源相关的标签，用于静默规则匹配，如 event_type
+	Labels map[string]string
+	// ExpiresAt 超过该时间仍未发送成功则不再重试，零值表示不过期
+	ExpiresAt time.Time
+	// Target 指定的发送目标，如群号，为空时使用目的地的默认配置
+	Target string
+	// IdempotencyKey 幂等键，同一来源已保存过时返回 repo.ErrDuplicateMessage，为空表示不去重
+	IdempotencyKey string
+}
+
+// labels 合并来源标签与内置标签，内置标签优先
+func (req SaveMessageRequest) labels() map[string]string {
+	labels := make(map[string]string, len(req.Labels)+4)
+	for k, v := range req.Labels {
+		labels[k] = v
+	}
+	labels["source"] = req.SourceType.String()
+	labels["destination"] = req.DestinationType.String()
+	labels["biz_id"] = req.BizID
+	labels["severity"] = req.severity().String()
+	return labels
+}
+
+func (req SaveMessageRequest) severity() message_box_enum.Severity {
+	if req.Severity == 0 {
+		return message_box_enum.SeverityInfo
+	}
+	return req.Severity
+}
+
+// ListMessagesRequest 分页查询消息的请求参数，零值表示不过滤
+type ListMessagesRequest struct {
+	Statuses        []message_box_enum.StatusType
+	SourceType      message_box_enum.SourceType
+	DestinationType message_box_enum.DestinationType
+	BizID           string
+	TraceID         string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	Keyword         string
+	Sort            string
+	Page            int
+	PerPage         int
 }
 
 func NewMessageBoxService(
 	napcatService *NapCatService,
+	rateLimitService *RateLimitService,
+	circuitBreaker *CircuitBreakerService,
+	muteService *MuteService,
 	messageBoxRepo repo.IMessageBoxRepo,
+	attemptRepo repo.IMessageAttemptRepo,
+	redactors *redact.Redactors,
+	metrics *metrics.Metrics,
 ) *MessageBoxService {
 	return &MessageBoxService{
-		napcatService:  napcatService,
-		messageBoxRepo: messageBoxRepo,
+		napcatService:    napcatService,
+		rateLimitService: rateLimitService,
+		circuitBreaker:   circuitBreaker,
+		muteService:      muteService,
+		messageBoxRepo:   messageBoxRepo,
+		attemptRepo:      attemptRepo,
+		redactors:        redactors,
+		metrics:          metrics,
 	}
 }
 
 func ProvideMessageBoxService(i do.Injector) (*MessageBoxService, error) {
 	napCatService := do.MustInvoke[*NapCatService](i)
+	rateLimitService := do.MustInvoke[*RateLimitService](i)
+	circuitBreaker := do.MustInvoke[*CircuitBreakerService](i)
+	muteService := do.MustInvoke[*MuteService](i)
 	messageBoxRepo := do.MustInvoke[repo.IMessageBoxRepo](i)
-	return NewMessageBoxService(napCatService, messageBoxRepo), nil
+	attemptRepo := do.MustInvoke[repo.IMessageAttemptRepo](i)
+	redactors := do.MustInvoke[*redact.Redactors](i)
+	metrics := do.MustInvoke[*metrics.Metrics](i)
+	return NewMessageBoxService(napCatService, rateLimitService, circuitBreaker, muteService, messageBoxRepo, attemptRepo, redactors, metrics), nil
 }
 
 // SaveAndSendMessage 保存并发送消息
@@ -48,15 +143,75 @@ func (s *MessageBoxService) SaveAndSendMessage(
 	ctx context.Context,
 	req SaveMessageRequest,
 ) (*model.MessageBoxModel, error) {
+	s.metrics.EventReceived(req.SourceType.String(), req.Labels["event_type"])
+
+	ctx, span := tracing.Start(ctx, "MessageBoxService.SaveAndSendMessage", trace.WithAttributes(
+		attribute.String("message.source", req.SourceType.String()),
+		attribute.String("message.destination", req.DestinationType.String()),
+		attribute.String("message.biz_id", req.BizID),
+	))
+	messageBox, err := s.saveAndSendMessage(ctx, req)
+	if messageBox != nil {
+		span.SetAttributes(attribute.String("message.id", messageBox.ID))
+	}
+	tracing.End(span, err)
+
+	return messageBox, err
+}
+
+func (s *MessageBoxService) saveAndSendMessage(ctx context.Context, req SaveMessageRequest) (*model.MessageBoxModel, error) {
+	// 免打扰和静默规则决定消息的初始状态
+	status, err := s.muteService.Check(ctx, MuteCheckIn{
+		DestinationType: req.DestinationType,
+		Severity:        req.severity(),
+		Labels:          req.labels(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check mute rules: %w", err)
+	}
+
+	messageBox, err := s.saveMessage(ctx, req, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != message_box_enum.Pending {
+		slog.InfoContext(ctx, "Message held by mute rules",
+			"message_id", messageBox.ID,
+			"status", status)
+		return messageBox, nil
+	}
+
+	return s.sendSavedMessage(ctx, messageBox)
+}
+
+// saveMessage 以指定的初始状态保存消息，不发送
+func (s *MessageBoxService) saveMessage(ctx context.Context, req SaveMessageRequest, status message_box_enum.StatusType) (*model.MessageBoxModel, error) {
+	// 原始请求可能包含令牌等敏感信息，按配置脱敏后再保存
+	if s.redactors.SourceRequest != nil {
+		req.SourceRequest = s.redactors.SourceRequest.JSON(req.SourceRequest)
+	}
+
 	// 保存消息到数据库
 	createMessageIn := repo.CreateMessageIn{
 		BizID:           req.BizID,
+		Status:          status,
 		Message:         req.Message,
 		SourceRequest:   req.SourceRequest,
 		SourceType:      req.SourceType,
 		DestinationType: req.DestinationType,
+		Severity:        req.severity(),
+		ExpiresAt:       req.ExpiresAt,
+		Target:          req.Target,
+		TraceID:         tracing.TraceID(ctx),
+		SpanID:          tracing.SpanID(ctx),
+		TraceFlags:      tracing.TraceFlags(ctx),
+		IdempotencyKey:  req.IdempotencyKey,
 	}
 	messageBox, err := s.messageBoxRepo.Create(ctx, createMessageIn)
+	if errors.Is(err, repo.ErrDuplicateMessage) {
+		return nil, err
+	}
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to save message",
 			"err", err,
@@ -67,17 +222,29 @@ func (s *MessageBoxService) SaveAndSendMessage(
 	slog.InfoContext(ctx, "Successfully saved message",
 		"message_id", messageBox.ID,
 		"biz_id", req.BizID)
+	return messageBox, nil
+}
 
-	// 发送消息
-	if err := s.SendMessage(ctx, messageBox); err != nil {
-		if err := s.messageSentFailureProcess(ctx, messageBox.ID, err); err != nil {
-			slog.ErrorContext(ctx, "messageSentFailureProcess finished with error", err)
+// sendSavedMessage 立即发送已保存的消息，发送失败的由重试任务继续发送
+func (s *MessageBoxService) sendSavedMessage(ctx context.Context, messageBox *model.MessageBoxModel) (*model.MessageBoxModel, error) {
+	receipt, err := s.SendMessage(ctx, messageBox, AttemptTriggerInline)
+	if err != nil {
+		if err := s.messageSentFailureProcess(ctx, messageBox, err); err != nil {
+			slog.ErrorContext(ctx, "messageSentFailureProcess finished with error", "err", err)
+		}
+		// 被限流或熔断的消息保持发送中状态，由重试任务延后发送，不视为失败
+		if isDeferred(err) {
+			slog.InfoContext(ctx, "Message deferred",
+				"reason", err,
+				"message_id", messageBox.ID,
+				"biz_id", messageBox.BizID)
+			return messageBox, nil
 		}
 		// 注意：这里返回了messageBox，即使发送失败，消息也已经保存
 		return messageBox, fmt.Errorf("message saved but failed to send: %w", err)
 	}
 
-	err = s.messageSentSuccessProcess(ctx, messageBox.ID)
+	err = s.messageSentSuccessProcess(ctx, messageBox.ID, receipt)
 	if err != nil {
 		return nil, fmt.Errorf("message sent success but change message status failed: %w", err)
 	}
@@ -85,35 +252,340 @@ func (s *MessageBoxService) SaveAndSendMessage(
 	return messageBox, nil
 }
 
-// SendMessage 发送消息，根据destination_type决定发送方式
-func (s *MessageBoxService) SendMessage(ctx context.Context, messageBox *model.MessageBoxModel) error {
+// isDeferred 被限流或熔断的消息未调用目的地，保持发送中状态由重试任务延后发送
+func isDeferred(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrCircuitOpen)
+}
+
+// deliveryAttempt 一次发送调用目的地的情况，被限流等未调用目的地时 called 为 false
+type deliveryAttempt struct {
+	called     bool
+	target     string
+	bot        string
+	duration   time.Duration
+	httpStatus int
+	retcode    int
+}
+
+// SendMessage 发送消息，根据destination_type决定发送方式，成功时返回发送回执。
+// 已保存的消息每次调用目的地都会记录发送历史，trigger 为触发方式
+func (s *MessageBoxService) SendMessage(ctx context.Context, messageBox *model.MessageBoxModel, trigger string) (*model.DeliveryReceipt, error) {
+	destination := messageBox.DestinationType.String()
+
+	ctx, span := tracing.Start(ctx, "MessageBoxService.SendMessage", trace.WithAttributes(
+		attribute.String("message.id", messageBox.ID),
+		attribute.String("message.destination", destination),
+	))
+	attempt := &deliveryAttempt{}
+	receipt, err := s.sendMessage(ctx, messageBox, trigger, attempt)
+	// 测试消息不保存，没有 ID，不记录发送历史
+	if attempt.called && messageBox.ID != "" {
+		s.recordAttempt(ctx, messageBox, trigger, attempt, err)
+	}
+	if receipt != nil {
+		span.SetAttributes(attribute.String("message.target", receipt.Target))
+	}
+
+	spanErr := err
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		// 被限流不视为错误
+		spanErr = nil
+		span.SetAttributes(attribute.Bool("message.rate_limited", true))
+		s.metrics.DeliveryFinished(destination, metrics.OutcomeRateLimited)
+	case errors.Is(err, ErrCircuitOpen):
+		// 熔断中延后发送，不视为错误
+		spanErr = nil
+		span.SetAttributes(attribute.Bool("message.circuit_open", true))
+		s.metrics.DeliveryFinished(destination, metrics.OutcomeCircuitOpen)
+	case err != nil:
+		s.metrics.DeliveryFinished(destination, metrics.OutcomeFailed)
+	default:
+		s.metrics.DeliveryFinished(destination, metrics.OutcomeSent)
+		// 测试消息不保存，没有创建时间
+		if createdAt, parseErr := strconv.ParseInt(messageBox.CreatedAt, 10, 64); parseErr == nil && createdAt > 0 {
+			s.metrics.ObserveDeliveryLatency(destination, time.Since(time.Unix(createdAt, 0)))
+		}
+	}
+	tracing.End(span, spanErr)
+
+	return receipt, err
+}
+
+// recordAttempt 保存发送历史，保存失败只记录日志，不影响发送结果
+func (s *MessageBoxService) recordAttempt(ctx context.Context, messageBox *model.MessageBoxModel, trigger string, attempt *deliveryAttempt, sendErr error) {
+	in := repo.CreateMessageAttemptIn{
+		MessageID:       messageBox.ID,
+		DestinationType: messageBox.DestinationType,
+		Target:          attempt.target,
+		Trigger:         trigger,
+		Duration:        attempt.duration,
+		HTTPStatus:      attempt.httpStatus,
+		Retcode:         attempt.retcode,
+		Bot:             attempt.bot,
+	}
+	if sendErr != nil {
+		in.Error = sendErr.Error()
+	}
+	if _, err := s.attemptRepo.Create(ctx, in); err != nil {
+		slog.ErrorContext(ctx, "Failed to record delivery attempt",
+			"err", err,
+			"message_id", messageBox.ID,
+			"trigger", trigger)
+	}
+}
+
+func (s *MessageBoxService) sendMessage(ctx context.Context, messageBox *model.MessageBoxModel, trigger string, attempt *deliveryAttempt) (*model.DeliveryReceipt, error) {
 	// 根据目的地类型选择发送方式
 	switch messageBox.DestinationType {
 	case message_box_enum.DestinationQQGroup:
-		return s.sendToQQGroup(ctx, messageBox)
+		return s.sendToQQGroup(ctx, messageBox, trigger, attempt)
+	default:
+		return nil, fmt.Errorf("unsupported destination type: %v", messageBox.DestinationType)
+	}
+}
+
+// deliveryTarget 消息的发送目标，未指定时使用目的地的默认配置
+func deliveryTarget(messageBox *model.MessageBoxModel) string {
+	if messageBox.Target != "" {
+		return messageBox.Target
+	}
+	switch messageBox.DestinationType {
+	case message_box_enum.DestinationQQGroup:
+		return config.GetConfig().NapCatConfig.GroupID
 	default:
-		return fmt.Errorf("unsupported destination type: %v", messageBox.DestinationType)
+		return ""
 	}
 }
 
 // sendToQQGroup 发送消息到QQ群
-func (s *MessageBoxService) sendToQQGroup(ctx context.Context, messageBox *model.MessageBoxModel) error {
-	groupID := config.GetConfig().NapCatConfig.GroupID
+func (s *MessageBoxService) sendToQQGroup(ctx context.Context, messageBox *model.MessageBoxModel, trigger string, attempt *deliveryAttempt) (*model.DeliveryReceipt, error) {
+	groupID := deliveryTarget(messageBox)
 
-	if err := s.napcatService.SendGroupMessage(ctx, groupID, messageBox.Message); err != nil {
+	// 熔断中直接延后，不占用限流令牌
+	done, err := s.circuitBreaker.Allow(ctx, message_box_enum.DestinationQQGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.acquireRateLimit(ctx, groupID, trigger); err != nil {
+		done(err)
+		return nil, err
+	}
+
+	attempt.called = true
+	attempt.target = groupID
+	attempt.bot = s.napcatService.Bot()
+	startedAt := time.Now()
+	result, err := s.napcatService.SendGroupMessage(ctx, groupID, messageBox.Message)
+	attempt.duration = time.Since(startedAt)
+	done(err)
+	var napcatErr *NapCatError
+	if errors.As(err, &napcatErr) {
+		attempt.httpStatus = napcatErr.HTTPStatus
+		attempt.retcode = napcatErr.Retcode
+	}
+	if err != nil {
 		slog.ErrorContext(ctx, "Failed to send message to QQ group",
 			"err", err,
 			"message_id", messageBox.ID,
 			"group_id", groupID)
-		return fmt.Errorf("failed to send message to QQ group: %w", err)
+		return nil, fmt.Errorf("failed to send message to QQ group: %w", err)
 	}
 
+	attempt.httpStatus = result.HTTPStatus
+	attempt.retcode = result.Retcode
+
 	slog.InfoContext(ctx, "Successfully sent message to QQ group",
 		"message_id", messageBox.ID,
 		"group_id", groupID)
+	return &model.DeliveryReceipt{
+		Target:     groupID,
+		ExternalID: strconv.FormatInt(result.MessageID, 10),
+	}, nil
+}
+
+// acquireRateLimit 获取发送令牌，重试任务不等待令牌，令牌不足的消息留到下次执行
+func (s *MessageBoxService) acquireRateLimit(ctx context.Context, groupID string, trigger string) error {
+	if trigger == AttemptTriggerRetry {
+		return s.rateLimitService.Allow(message_box_enum.DestinationQQGroup, groupID)
+	}
+	return s.rateLimitService.Wait(ctx, message_box_enum.DestinationQQGroup, groupID)
+}
+
+// SendMuteDigest 将免打扰结束的目的地暂存的消息合并为一条摘要发送
+func (s *MessageBoxService) SendMuteDigest(ctx context.Context) error {
+	heldMessages, err := s.messageBoxRepo.ListByStatus(ctx, message_box_enum.Muted)
+	if err != nil {
+		return fmt.Errorf("failed to list muted messages: %w", err)
+	}
+
+	now := time.Now()
+	grouped := lo.GroupBy(heldMessages, func(m *model.MessageBoxModel) message_box_enum.DestinationType {
+		return m.DestinationType
+	})
+	for destination, messages := range grouped {
+		if s.muteService.InQuietHours(destination, now) {
+			continue
+		}
+
+		ids := lo.Map(messages, func(m *model.MessageBoxModel, _ int) string {
+			return m.ID
+		})
+		sourceRequest, err := json.Marshal(map[string]any{"message_ids": ids})
+		if err != nil {
+			return fmt.Errorf("marshal digest source request: %w", err)
+		}
+
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "🌅 免打扰期间暂存了 %d 条消息", len(messages))
+		for _, m := range messages {
+			builder.WriteString("\n━━━━━━━━━━\n")
+			builder.WriteString(m.Message)
+		}
+
+		// 先保存摘要再标记暂存的消息，摘要不经过免打扰和静默规则，发送失败时由重试任务重试摘要
+		digest, err := s.saveMessage(ctx, SaveMessageRequest{
+			BizID:           fmt.Sprintf("digest-%s-%d", destination, now.Unix()),
+			Message:         builder.String(),
+			SourceRequest:   string(sourceRequest),
+			SourceType:      message_box_enum.SourceTypeDigest,
+			DestinationType: destination,
+			Severity:        message_box_enum.SeverityInfo,
+		}, message_box_enum.Pending)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to save mute digest",
+				"err", err,
+				"destination", destination,
+				"count", len(messages))
+			continue
+		}
+
+		// 暂存的消息由摘要代为发送，回执中记录摘要的 ID
+		receiptJSON, err := json.Marshal(model.DeliveryReceipt{DigestID: digest.ID})
+		if err != nil {
+			return fmt.Errorf("marshal digest receipt: %w", err)
+		}
+		if err := s.messageBoxRepo.UpdateByIDs(ctx, ids, map[string]any{
+			"status":       message_box_enum.Sent.Val(),
+			"last_sent_at": now.Unix(),
+			"receipt":      string(receiptJSON),
+		}); err != nil {
+			slog.ErrorContext(ctx, "Failed to mark muted messages as digested",
+				"err", err,
+				"destination", destination,
+				"digest_id", digest.ID)
+			// 取消摘要，暂存的消息保持原状态，下次重新合并
+			if err := s.messageBoxRepo.UpdateByID(ctx, digest.ID, map[string]any{
+				"status":     message_box_enum.Cancelled.Val(),
+				"last_error": "failed to mark muted messages as digested",
+			}); err != nil {
+				slog.ErrorContext(ctx, "Failed to cancel mute digest",
+					"err", err,
+					"digest_id", digest.ID)
+			}
+			continue
+		}
+
+		if _, err := s.sendSavedMessage(ctx, digest); err != nil {
+			slog.ErrorContext(ctx, "Failed to send mute digest",
+				"err", err,
+				"destination", destination,
+				"digest_id", digest.ID,
+				"count", len(messages))
+			continue
+		}
+
+		slog.InfoContext(ctx, "Successfully sent mute digest",
+			"destination", destination,
+			"count", len(messages))
+	}
+
 	return nil
 }
 
+// ListMessages 分页查询消息，返回当前页和总数
+func (s *MessageBoxService) ListMessages(ctx context.Context, req ListMessagesRequest) ([]*model.MessageBoxModel, int64, error) {
+	if column := strings.TrimPrefix(req.Sort, "-"); column != "" && !repo.IsMessageBoxSortColumn(column) {
+		return nil, 0, fmt.Errorf("%w: unsupported sort column %s", ErrInvalidArgument, column)
+	}
+	messages, total, err := s.messageBoxRepo.List(ctx, repo.ListMessagesIn{
+		Statuses:        req.Statuses,
+		SourceType:      req.SourceType,
+		DestinationType: req.DestinationType,
+		BizID:           req.BizID,
+		TraceID:         req.TraceID,
+		CreatedFrom:     req.CreatedFrom,
+		CreatedTo:       req.CreatedTo,
+		Keyword:         req.Keyword,
+		Sort:            req.Sort,
+		Page:            max(req.Page, 1),
+		PerPage:         req.PerPage,
+	})
+	if errors.Is(err, repo.ErrKeywordSearchEncrypted) {
+		return nil, 0, fmt.Errorf("%w: %w", ErrInvalidArgument, err)
+	}
+	return messages, total, err
+}
+
+// MessageStats 消息统计，按状态名称和目的地名称汇总
+type MessageStats struct {
+	Total         int64                       `json:"total"`
+	ByStatus      map[string]int64            `json:"by_status"`
+	ByDestination map[string]map[string]int64 `json:"by_destination"`
+}
+
+// Stats 统计各状态、各目的地的消息数
+func (s *MessageBoxService) Stats(ctx context.Context) (*MessageStats, error) {
+	counts, err := s.messageBoxRepo.CountByStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	stats := &MessageStats{
+		ByStatus:      make(map[string]int64),
+		ByDestination: make(map[string]map[string]int64),
+	}
+	for _, count := range counts {
+		destination := count.DestinationType.String()
+		if stats.ByDestination[destination] == nil {
+			stats.ByDestination[destination] = make(map[string]int64)
+		}
+		stats.Total += count.Count
+		stats.ByStatus[count.Status.String()] += count.Count
+		stats.ByDestination[destination][count.Status.String()] += count.Count
+	}
+	return stats, nil
+}
+
+// TestDestinationRequest 测试目的地的请求参数
+type TestDestinationRequest struct {
+	DestinationType message_box_enum.DestinationType
+	// Target 发送目标，为空时使用目的地的默认配置
+	Target  string
+	Message string
+}
+
+// TestDestination 直接向目的地发送一条测试消息，不保存到消息表，用于排查目的地配置和连通性
+func (s *MessageBoxService) TestDestination(ctx context.Context, req TestDestinationRequest) (*model.DeliveryReceipt, error) {
+	return s.SendMessage(ctx, &model.MessageBoxModel{
+		Message:         req.Message,
+		SourceType:      message_box_enum.SourceTypeCLI,
+		DestinationType: req.DestinationType,
+		Severity:        message_box_enum.SeverityInfo,
+		Target:          req.Target,
+	}, AttemptTriggerManual)
+}
+
+// ListAttempts 按时间顺序查询消息的发送历史
+func (s *MessageBoxService) ListAttempts(ctx context.Context, messageID string) ([]*model.MessageAttemptModel, error) {
+	return s.attemptRepo.ListByMessageID(ctx, messageID)
+}
+
+// GetMessage 查询消息详情，不存在时返回 nil
+func (s *MessageBoxService) GetMessage(ctx context.Context, messageID string) (*model.MessageBoxModel, error) {
+	return s.messageBoxRepo.GetByID(ctx, messageID)
+}
+
 // 状态为发送中，且创建时间超过一分钟的即为发送失败的消息
 func (s *MessageBoxService) findFailedMessages(ctx context.Context) ([]*model.MessageBoxModel, error) {
 	// 查找创建时间超过1分钟的发送中消息
@@ -121,19 +593,54 @@ func (s *MessageBoxService) findFailedMessages(ctx context.Context) ([]*model.Me
 	return s.messageBoxRepo.ListFailedBefore(ctx, oneMinuteAgo)
 }
 
-// 修改消息状态为发送成功
-func (s *MessageBoxService) messageSentSuccessProcess(ctx context.Context, messageID int32) error {
+// 修改消息状态为发送成功，记录发送回执
+func (s *MessageBoxService) messageSentSuccessProcess(ctx context.Context, messageID string, receipt *model.DeliveryReceipt) error {
+	receiptJSON, err := json.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("marshal delivery receipt: %w", err)
+	}
 	return s.messageBoxRepo.UpdateByID(ctx, messageID, map[string]any{
-		"status":       message_box_enum.Sent,
+		"status":       message_box_enum.Sent.Val(),
 		"last_sent_at": time.Now().Unix(),
+		"attempts+":    1,
+		"receipt":      string(receiptJSON),
 	})
 }
 
-func (s *MessageBoxService) messageSentFailureProcess(ctx context.Context, messageID int32, err error) error {
-	return s.messageBoxRepo.UpdateByID(ctx, messageID, map[string]any{
+// 记录发送失败原因，被限流延后的不计入发送次数；发送次数达到上限时转为死信，不再重试
+func (s *MessageBoxService) messageSentFailureProcess(ctx context.Context, messageBox *model.MessageBoxModel, err error) error {
+	data := map[string]any{
 		"last_sent_at": time.Now().Unix(),
 		"last_error":   err.Error(),
-	})
+	}
+	deadLetter := false
+	if !isDeferred(err) {
+		data["attempts+"] = 1
+		if limit := maxAttempts(); limit > 0 && messageBox.Attempts+1 >= limit {
+			data["status"] = message_box_enum.DeadLetter.Val()
+			deadLetter = true
+		}
+	}
+	if err := s.messageBoxRepo.UpdateByID(ctx, messageBox.ID, data); err != nil {
+		return err
+	}
+
+	if deadLetter {
+		slog.WarnContext(ctx, "Message moved to dead letter after reaching max attempts",
+			"message_id", messageBox.ID,
+			"biz_id", messageBox.BizID,
+			"attempts", messageBox.Attempts+1)
+	}
+	return nil
+}
+
+// maxAttempts 当前配置的最多发送次数，零值使用默认值，小于 0 表示不限制
+func maxAttempts() int32 {
+	limit := config.GetConfig().Retry.MaxAttempts
+	if limit == 0 {
+		return defaultMaxAttempts
+	}
+	return int32(limit)
 }
 
 func (s *MessageBoxService) MessageRetry(ctx context.Context) error {
@@ -150,27 +657,91 @@ func (s *MessageBoxService) MessageRetry(ctx context.Context) error {
 
 	slog.InfoContext(ctx, "Found failed messages to retry", "count", len(sentFailedMessages))
 
+	now := time.Now()
+	skipped := 0
+	// rateLimited 本次执行中令牌已用完的发送目标
+	rateLimited := make(map[circuitKey]bool)
 	for _, sentFailedMessage := range sentFailedMessages {
-		if err = s.SendMessage(ctx, sentFailedMessage); err != nil {
-			slog.ErrorContext(ctx, "Failed to resend message",
-				"err", err,
-				"message_id", sentFailedMessage.ID,
-				"biz_id", sentFailedMessage.BizID)
-			// 继续尝试其他消息，不立即返回错误
+		key := circuitKey{destination: sentFailedMessage.DestinationType, target: deliveryTarget(sentFailedMessage)}
+		// 熔断中或令牌已用完的发送目标不调用目的地，也不更新消息、不计入重试次数，留到之后的执行；过期的消息仍标记为过期
+		if !isExpired(sentFailedMessage, now) &&
+			(rateLimited[key] || s.circuitBreaker.IsOpen(key.destination, key.target)) {
+			skipped++
 			continue
 		}
+		if err := s.retryMessage(ctx, sentFailedMessage, now); errors.Is(err, ErrRateLimited) {
+			rateLimited[key] = true
+		}
+	}
+	if skipped > 0 {
+		slog.InfoContext(ctx, "Skipped messages whose circuit is open or rate limit is exhausted", "count", skipped)
+	}
 
-		err = s.messageSentSuccessProcess(ctx, sentFailedMessage.ID)
-		if err != nil {
-			slog.ErrorContext(ctx, "Message resent success but change message status failed",
+	return nil
+}
+
+// isExpired 消息设置了过期时间且已过期
+func isExpired(messageBox *model.MessageBoxModel, now time.Time) bool {
+	return messageBox.ExpiresAt > 0 && now.Unix() > messageBox.ExpiresAt
+}
+
+// retryMessage 在创建消息时的 trace 中重试单条消息，日志可按原始请求的 trace_id 查到，并通过 link 关联本次重试任务；
+// 返回发送的错误，已记录日志
+func (s *MessageBoxService) retryMessage(ctx context.Context, sentFailedMessage *model.MessageBoxModel, now time.Time) error {
+	link := trace.LinkFromContext(ctx)
+	ctx = tracing.ContextWithRemoteParent(ctx, sentFailedMessage.TraceID, sentFailedMessage.SpanID, sentFailedMessage.TraceFlags)
+	ctx, span := tracing.Start(ctx, "MessageBoxService.retryMessage",
+		trace.WithLinks(link),
+		trace.WithAttributes(
+			attribute.String("message.id", sentFailedMessage.ID),
+			attribute.Int("message.attempt", int(sentFailedMessage.Attempts)+1),
+		),
+	)
+	defer span.End()
+
+	if isExpired(sentFailedMessage, now) {
+		if err := s.messageBoxRepo.UpdateByID(ctx, sentFailedMessage.ID, map[string]any{
+			"status": message_box_enum.Expired.Val(),
+		}); err != nil {
+			slog.ErrorContext(ctx, "Failed to mark message as expired",
 				"err", err,
 				"message_id", sentFailedMessage.ID)
-			// 继续处理其他消息
+			return nil
+		}
+		slog.WarnContext(ctx, "Message expired before it could be sent",
+			"message_id", sentFailedMessage.ID,
+			"biz_id", sentFailedMessage.BizID)
+		return nil
+	}
+
+	s.metrics.RetryAttempted(sentFailedMessage.DestinationType.String())
+	receipt, err := s.SendMessage(ctx, sentFailedMessage, AttemptTriggerRetry)
+	if err != nil {
+		if err := s.messageSentFailureProcess(ctx, sentFailedMessage, err); err != nil {
+			slog.ErrorContext(ctx, "messageSentFailureProcess finished with error", "err", err)
 		}
-		slog.InfoContext(ctx, "Successfully resent message",
+		if isDeferred(err) {
+			slog.InfoContext(ctx, "Message deferred",
+				"reason", err,
+				"message_id", sentFailedMessage.ID,
+				"biz_id", sentFailedMessage.BizID)
+			return err
+		}
+		slog.ErrorContext(ctx, "Failed to resend message",
+			"err", err,
 			"message_id", sentFailedMessage.ID,
 			"biz_id", sentFailedMessage.BizID)
+		return err
 	}
 
+	err = s.messageSentSuccessProcess(ctx, sentFailedMessage.ID, receipt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Message resent success but change message status failed",
+			"err", err,
+			"message_id", sentFailedMessage.ID)
+	}
+	slog.InfoContext(ctx, "Successfully resent message",
+		"message_id", sentFailedMessage.ID,
+		"biz_id", sentFailedMessage.BizID)
 	return nil
 }
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/repo"
+	"slices"
+	"time"
+
+	"github.com/samber/do/v2"
+)
+
+// maxBulkRetry 单次批量重试最多处理的消息数
+const maxBulkRetry = 1000
+
+// 人工操作类型
+const (
+	OperationResend = "resend"
+	OperationCancel = "cancel"
+	OperationRetry  = "retry"
+)
+
+// ErrMessageNotFound 消息不存在
+var ErrMessageNotFound = errors.New("message not found")
+
+// retryableStatuses 允许批量重试的消息状态
+var retryableStatuses = []message_box_enum.StatusType{
+	message_box_enum.Pending,
+	message_box_enum.Expired,
+	message_box_enum.DeadLetter,
+}
+
+// MessageOperationService 人工重发、取消和批量重试消息，发送统一复用 MessageBoxService.SendMessage
+type MessageOperationService struct {
+	messageBoxService *MessageBoxService
+	messageBoxRepo    repo.IMessageBoxRepo
+}
+
+// ResendMessageRequest 重发消息的请求参数
+type ResendMessageRequest struct {
+	MessageID string
+	// DestinationType 和 Target 任一不为空时，复制一条新消息发送到指定目的地
+	DestinationType message_box_enum.DestinationType
+	Target          string
+	OperatedBy      string
+	DryRun          bool
+}
+
+// CancelMessageRequest 取消消息的请求参数
+type CancelMessageRequest struct {
+	MessageID  string
+	OperatedBy string
+	DryRun     bool
+}
+
+// BulkRetryRequest 批量重试的请求参数，Filter.Statuses 为空时重试发送中、已过期和死信消息
+type BulkRetryRequest struct {
+	Filter     ListMessagesRequest
+	OperatedBy string
+	DryRun     bool
+}
+
+// OperationResult 人工操作结果
+type OperationResult struct {
+	DryRun bool `json:"dry_run"`
+	// Matched 受影响的消息数
+	Matched   int64 `json:"matched"`
+	Sent      int   `json:"sent"`
+	Deferred  int   `json:"deferred"`
+	Failed    int   `json:"failed"`
+	Cancelled int   `json:"cancelled"`
+	// Truncated 匹配的消息超过单次处理上限，剩余的需要再次调用
+	Truncated bool `json:"truncated"`
+	// MessageIDs 实际发送或取消的消息 ID，重发到其他目的地时为新消息的 ID
+	MessageIDs []string `json:"message_ids"`
+}
+
+func NewMessageOperationService(
+	messageBoxService *MessageBoxService,
+	messageBoxRepo repo.IMessageBoxRepo,
+) *MessageOperationService {
+	return &MessageOperationService{
+		messageBoxService: messageBoxService,
+		messageBoxRepo:    messageBoxRepo,
+	}
+}
+
+func ProvideMessageOperationService(i do.Injector) (*MessageOperationService, error) {
+	messageBoxService := do.MustInvoke[*MessageBoxService](i)
+	messageBoxRepo := do.MustInvoke[repo.IMessageBoxRepo](i)
+	return NewMessageOperationService(messageBoxService, messageBoxRepo), nil
+}
+
+// Resend 重发单条消息，可选发送到其他目的地或目标
+func (s *MessageOperationService) Resend(ctx context.Context, req ResendMessageRequest) (*OperationResult, error) {
+	messageBox, err := s.messageBoxRepo.GetByID(ctx, req.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if messageBox == nil {
+		return nil, ErrMessageNotFound
+	}
+
+	result := &OperationResult{DryRun: req.DryRun, Matched: 1}
+	if req.DryRun {
+		return result, nil
+	}
+
+	if err := s.markOperated(ctx, messageBox.ID, OperationResend, req.OperatedBy, nil); err != nil {
+		return nil, err
+	}
+
+	target := messageBox
+	if req.DestinationType != 0 || req.Target != "" {
+		destination := req.DestinationType
+		if destination == 0 {
+			destination = messageBox.DestinationType
+		}
+		// 复制为新消息，保留原消息的发送记录；人工操作不再经过免打扰规则
+		target, err = s.messageBoxRepo.Create(ctx, repo.CreateMessageIn{
+			BizID:           messageBox.BizID,
+			Message:         messageBox.Message,
+			SourceRequest:   messageBox.SourceRequest,
+			SourceType:      messageBox.SourceType,
+			DestinationType: destination,
+			Severity:        messageBox.Severity,
+			Target:          req.Target,
+			TraceID:         messageBox.TraceID,
+			SpanID:          messageBox.SpanID,
+			TraceFlags:      messageBox.TraceFlags,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy message: %w", err)
+		}
+		if err := s.markOperated(ctx, target.ID, OperationResend, req.OperatedBy, nil); err != nil {
+			return nil, err
+		}
+	} else if err := s.markOperated(ctx, messageBox.ID, OperationResend, req.OperatedBy, map[string]any{
+		// 重新进入发送中状态，发送失败时由重试任务继续处理
+		"status":     message_box_enum.Pending.Val(),
+		"expires_at": 0,
+	}); err != nil {
+		return nil, err
+	}
+
+	s.deliver(ctx, target, result)
+	return result, nil
+}
+
+// Cancel 取消发送中或免打扰暂存的消息
+func (s *MessageOperationService) Cancel(ctx context.Context, req CancelMessageRequest) (*OperationResult, error) {
+	messageBox, err := s.messageBoxRepo.GetByID(ctx, req.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if messageBox == nil {
+		return nil, ErrMessageNotFound
+	}
+
+	status := message_box_enum.StatusType(messageBox.Status)
+	if status != message_box_enum.Pending && status != message_box_enum.Muted {
+		return nil, fmt.Errorf("%w: message is %s, only pending or muted messages can be cancelled", ErrInvalidArgument, status)
+	}
+
+	result := &OperationResult{DryRun: req.DryRun, Matched: 1}
+	if req.DryRun {
+		return result, nil
+	}
+
+	if err := s.markOperated(ctx, messageBox.ID, OperationCancel, req.OperatedBy, map[string]any{
+		"status": message_box_enum.Cancelled.Val(),
+	}); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Message cancelled",
+		"message_id", messageBox.ID,
+		"operated_by", req.OperatedBy)
+	result.Cancelled = 1
+	result.MessageIDs = []string{messageBox.ID}
+	return result, nil
+}
+
+// BulkRetry 按条件批量重试消息，DryRun 时只返回匹配的数量
+func (s *MessageOperationService) BulkRetry(ctx context.Context, req BulkRetryRequest) (*OperationResult, error) {
+	filter := req.Filter
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = retryableStatuses
+	}
+	for _, status := range filter.Statuses {
+		if !slices.Contains(retryableStatuses, status) {
+			return nil, fmt.Errorf("%w: %s messages cannot be retried", ErrInvalidArgument, status)
+		}
+	}
+	filter.Sort = "-severity"
+	filter.Page = 1
+	filter.PerPage = 200
+
+	// 先收集全部匹配的消息，避免发送后状态变化影响分页
+	messages := make([]*model.MessageBoxModel, 0)
+	var total int64
+	for len(messages) < maxBulkRetry {
+		page, count, err := s.messageBoxService.ListMessages(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+		total = count
+		if req.DryRun {
+			break
+		}
+		messages = append(messages, page...)
+		if len(page) < filter.PerPage {
+			break
+		}
+		filter.Page++
+	}
+	if len(messages) > maxBulkRetry {
+		messages = messages[:maxBulkRetry]
+	}
+
+	result := &OperationResult{
+		DryRun:    req.DryRun,
+		Matched:   total,
+		Truncated: !req.DryRun && total > int64(len(messages)),
+	}
+	if req.DryRun {
+		return result, nil
+	}
+
+	for _, messageBox := range messages {
+		if err := s.markOperated(ctx, messageBox.ID, OperationRetry, req.OperatedBy, map[string]any{
+			"status":     message_box_enum.Pending.Val(),
+			"expires_at": 0,
+		}); err != nil {
+			slog.ErrorContext(ctx, "Failed to mark message for retry",
+				"err", err,
+				"message_id", messageBox.ID)
+			result.Failed++
+			continue
+		}
+		s.deliver(ctx, messageBox, result)
+	}
+
+	slog.InfoContext(ctx, "Bulk retry finished",
+		"operated_by", req.OperatedBy,
+		"matched", result.Matched,
+		"sent", result.Sent,
+		"deferred", result.Deferred,
+		"failed", result.Failed)
+	return result, nil
+}
+
+// deliver 发送消息并记录结果，发送失败的消息保持发送中状态由重试任务继续处理
+func (s *MessageOperationService) deliver(ctx context.Context, messageBox *model.MessageBoxModel, result *OperationResult) {
+	result.MessageIDs = append(result.MessageIDs, messageBox.ID)
+
+	receipt, err := s.messageBoxService.SendMessage(ctx, messageBox, AttemptTriggerManual)
+	if err != nil {
+		if err := s.messageBoxService.messageSentFailureProcess(ctx, messageBox, err); err != nil {
+			slog.ErrorContext(ctx, "messageSentFailureProcess finished with error", "err", err)
+		}
+		if isDeferred(err) {
+			result.Deferred++
+			return
+		}
+		result.Failed++
+		return
+	}
+
+	if err := s.messageBoxService.messageSentSuccessProcess(ctx, messageBox.ID, receipt); err != nil {
+		slog.ErrorContext(ctx, "Message sent success but change message status failed",
+			"err", err,
+			"message_id", messageBox.ID)
+	}
+	result.Sent++
+}
+
+// markOperated 记录人工操作及操作人，data 为需要同时更新的字段
+func (s *MessageOperationService) markOperated(ctx context.Context, messageID string, operation, operatedBy string, data map[string]any) error {
+	update := map[string]any{
+		"operation":   operation,
+		"operated_by": operatedBy,
+		"operated_at": time.Now().Unix(),
+	}
+	for k, v := range data {
+		update[k] = v
+	}
+	if err := s.messageBoxRepo.UpdateByID(ctx, messageID, update); err != nil {
+		return fmt.Errorf("failed to record %s operation: %w", operation, err)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/repo"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/do/v2"
+)
+
+// ErrSilenceNotFound 静默规则不存在
+var ErrSilenceNotFound = errors.New("silence not found")
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// muteSchedule 解析后的免打扰时间段
+type muteSchedule struct {
+	name         string
+	destinations map[message_box_enum.DestinationType]struct{}
+	location     *time.Location
+	// start 和 end 为当天的分钟数
+	start       int
+	end         int
+	weekdays    map[time.Weekday]struct{}
+	minSeverity message_box_enum.Severity
+}
+
+// MuteService 免打扰与静默规则服务
+type MuteService struct {
+	schedules   atomic.Pointer[[]*muteSchedule]
+	silenceRepo repo.IMessageSilenceRepo
+}
+
+// MuteCheckIn 免打扰检查参数
+type MuteCheckIn struct {
+	DestinationType message_box_enum.DestinationType
+	Severity        message_box_enum.Severity
+	Labels          map[string]string
+}
+
+// CreateSilenceRequest 创建静默规则的请求参数
+type CreateSilenceRequest struct {
+	Matchers  []model.SilenceMatcher
+	Comment   string
+	CreatedBy string
+	StartsAt  time.Time
+	EndsAt    time.Time
+}
+
+// NewMuteService 创建免打扰服务实例
+func NewMuteService(cfg *config.Config, silenceRepo repo.IMessageSilenceRepo) (*MuteService, error) {
+	schedules, err := parseMuteSchedules(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MuteService{
+		silenceRepo: silenceRepo,
+	}
+	s.schedules.Store(&schedules)
+	return s, nil
+}
+
+func ProvideMuteService(i do.Injector) (*MuteService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	silenceRepo := do.MustInvoke[repo.IMessageSilenceRepo](i)
+	return NewMuteService(cfg, silenceRepo)
+}
+
+// Reload 使用新配置的免打扰时间段，解析失败时保留原配置
+func (s *MuteService) Reload(cfg *config.Config) error {
+	schedules, err := parseMuteSchedules(cfg)
+	if err != nil {
+		return err
+	}
+	s.schedules.Store(&schedules)
+	return nil
+}
+
+// parseMuteSchedules 解析全部免打扰时间段，返回全部时间段的错误
+func parseMuteSchedules(cfg *config.Config) ([]*muteSchedule, error) {
+	v := &config.Validator{}
+	schedules := make([]*muteSchedule, 0, len(cfg.Mute.Schedules))
+	for idx, item := range cfg.Mute.Schedules {
+		schedule, err := parseMuteSchedule(fmt.Sprintf("mute.schedules[%d]", idx), item)
+		if err != nil {
+			v.Join(err)
+			continue
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// Check 判断消息应直接发送、暂存还是静默，返回消息的初始状态
+func (s *MuteService) Check(ctx context.Context, in MuteCheckIn) (message_box_enum.StatusType, error) {
+	now := time.Now()
+
+	silences, err := s.silenceRepo.ListActiveAt(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list active silences: %w", err)
+	}
+	for _, silence := range silences {
+		if matchSilence(silence.Matchers, in.Labels) {
+			return message_box_enum.Silenced, nil
+		}
+	}
+
+	for _, schedule := range *s.schedules.Load() {
+		if in.Severity >= schedule.minSeverity {
+			continue
+		}
+		if schedule.appliesTo(in.DestinationType) && schedule.activeAt(now) {
+			return message_box_enum.Muted, nil
+		}
+	}
+
+	return message_box_enum.Pending, nil
+}
+
+// InQuietHours 判断目的地当前是否处于免打扰时间段
+func (s *MuteService) InQuietHours(destination message_box_enum.DestinationType, t time.Time) bool {
+	for _, schedule := range *s.schedules.Load() {
+		if schedule.appliesTo(destination) && schedule.activeAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateSilence 创建静默规则
+func (s *MuteService) CreateSilence(ctx context.Context, req CreateSilenceRequest) (*model.MessageSilenceModel, error) {
+	if len(req.Matchers) == 0 {
+		return nil, fmt.Errorf("at least one matcher is required")
+	}
+	for _, matcher := range req.Matchers {
+		if matcher.Name == "" {
+			return nil, fmt.Errorf("matcher name is required")
+		}
+		if matcher.IsRegex {
+			if _, err := compileMatcherRegex(matcher.Value); err != nil {
+				return nil, fmt.Errorf("invalid regex for matcher %q: %w", matcher.Name, err)
+			}
+		}
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		return nil, fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	return s.silenceRepo.Create(ctx, repo.CreateSilenceIn{
+		Matchers:  req.Matchers,
+		Comment:   req.Comment,
+		CreatedBy: req.CreatedBy,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+	})
+}
+
+// ListSilences 查询生效中以及尚未开始的静默规则
+func (s *MuteService) ListSilences(ctx context.Context) ([]*model.MessageSilenceModel, error) {
+	return s.silenceRepo.ListNotExpired(ctx, time.Now())
+}
+
+// ExpireSilence 使静默规则立即失效，不存在时返回 ErrSilenceNotFound
+func (s *MuteService) ExpireSilence(ctx context.Context, silenceID int32) error {
+	found, err := s.silenceRepo.ExpireByID(ctx, silenceID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSilenceNotFound
+	}
+	return nil
+}
+
+// matchSilence 所有匹配条件都满足时静默规则命中
+func matchSilence(matchers []model.SilenceMatcher, labels map[string]string) bool {
+	for _, matcher := range matchers {
+		value := labels[matcher.Name]
+		if matcher.IsRegex {
+			re, err := compileMatcherRegex(matcher.Value)
+			if err != nil || !re.MatchString(value) {
+				return false
+			}
+			continue
+		}
+		if value != matcher.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// compileMatcherRegex 正则匹配需要完整匹配标签值，与 Alertmanager 一致
+func compileMatcherRegex(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// parseMuteSchedule 解析免打扰时间段，path 为时间段在配置中的路径
+func parseMuteSchedule(path string, item config.MuteSchedule) (*muteSchedule, error) {
+	v := &config.Validator{}
+	schedule := &muteSchedule{
+		name:         item.Name,
+		destinations: make(map[message_box_enum.DestinationType]struct{}),
+		location:     time.Local,
+		weekdays:     make(map[time.Weekday]struct{}),
+		minSeverity:  message_box_enum.SeverityCritical,
+	}
+
+	for idx, name := range item.Destinations {
+		destination, ok := message_box_enum.ParseDestinationType(name)
+		if !ok {
+			v.Add(fmt.Sprintf("%s.destinations[%d]", path, idx), "unknown destination %q", name)
+			continue
+		}
+		schedule.destinations[destination] = struct{}{}
+	}
+
+	if item.Timezone != "" {
+		location, err := time.LoadLocation(item.Timezone)
+		if err != nil {
+			v.AddError(path+".timezone", err)
+		} else {
+			schedule.location = location
+		}
+	}
+
+	var err error
+	if schedule.start, err = parseClock(item.Start); err != nil {
+		v.AddError(path+".start", err)
+	}
+	if schedule.end, err = parseClock(item.End); err != nil {
+		v.AddError(path+".end", err)
+	}
+
+	for idx, name := range item.Weekdays {
+		weekday, ok := weekdayNames[strings.ToLower(name)]
+		if !ok {
+			v.Add(fmt.Sprintf("%s.weekdays[%d]", path, idx), "unknown weekday %q", name)
+			continue
+		}
+		schedule.weekdays[weekday] = struct{}{}
+	}
+
+	if item.MinSeverity != "" {
+		severity, ok := message_box_enum.ParseSeverity(item.MinSeverity)
+		if !ok {
+			v.Add(path+".min_severity", "unknown severity %q", item.MinSeverity)
+		} else {
+			schedule.minSeverity = severity
+		}
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回当天的分钟数
+func parseClock(clock string) (int, error) {
+	hour, minute, ok := strings.Cut(clock, ":")
+	if !ok {
+		return 0, fmt.Errorf("%q is not in HH:MM format", clock)
+	}
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("%q has invalid hour", clock)
+	}
+	m, err := strconv.Atoi(minute)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("%q has invalid minute", clock)
+	}
+	return h*60 + m, nil
+}
+
+func (m *muteSchedule) appliesTo(destination message_box_enum.DestinationType) bool {
+	if len(m.destinations) == 0 {
+		return true
+	}
+	_, ok := m.destinations[destination]
+	return ok
+}
+
+func (m *muteSchedule) activeAt(t time.Time) bool {
+	local := t.In(m.location)
+	minute := local.Hour()*60 + local.Minute()
+	weekday := local.Weekday()
+
+	switch {
+	case m.start < m.end:
+		if minute < m.start || minute >= m.end {
+			return false
+		}
+	case minute >= m.start:
+		// 跨天时间段的前半段
+	case minute < m.end:
+		// 跨天时间段的后半段，星期以开始的那天为准
+		weekday = (weekday + 6) % 7
+	default:
+		return false
+	}
+
+	if len(m.weekdays) == 0 {
+		return true
+	}
+	_, ok := m.weekdays[weekday]
+	return ok
+}
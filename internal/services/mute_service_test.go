@@ -0,0 +1,279 @@
+package services
+
+import (
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/define/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMuteSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    config.MuteSchedule
+		wantErr []string
+	}{
+		{
+			name: "valid",
+			item: config.MuteSchedule{
+				Destinations: []string{"qq-group"},
+				Timezone:     "Asia/Shanghai",
+				Start:        "22:00",
+				End:          "08:00",
+				Weekdays:     []string{"Mon", "fri"},
+				MinSeverity:  "warning",
+			},
+		},
+		{
+			name: "invalid clock",
+			item: config.MuteSchedule{Start: "25:00", End: "8"},
+			wantErr: []string{
+				`mute.schedules[0].start: "25:00" has invalid hour`,
+				`mute.schedules[0].end: "8" is not in HH:MM format`,
+			},
+		},
+		{
+			name: "invalid minute",
+			item: config.MuteSchedule{Start: "22:60", End: "08:00"},
+			wantErr: []string{
+				`mute.schedules[0].start: "22:60" has invalid minute`,
+			},
+		},
+		{
+			name: "unknown names",
+			item: config.MuteSchedule{
+				Destinations: []string{"qq-group", "email"},
+				Timezone:     "Mars/Olympus",
+				Start:        "22:00",
+				End:          "08:00",
+				Weekdays:     []string{"mon", "someday"},
+				MinSeverity:  "fatal",
+			},
+			wantErr: []string{
+				`mute.schedules[0].destinations[1]: unknown destination "email"`,
+				"mute.schedules[0].timezone:",
+				`mute.schedules[0].weekdays[1]: unknown weekday "someday"`,
+				`mute.schedules[0].min_severity: unknown severity "fatal"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseMuteSchedule("mute.schedules[0]", tt.item)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("parseMuteSchedule() error = %v", err)
+				}
+				if schedule == nil {
+					t.Fatal("parseMuteSchedule() = nil")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("parseMuteSchedule() error = nil, want error")
+			}
+			// 一次列出全部错误
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("parseMuteSchedule() error = %v, want containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMuteScheduleActiveAt(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-10-16 是星期五
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, shanghai)
+	}
+
+	tests := []struct {
+		name string
+		item config.MuteSchedule
+		t    time.Time
+		want bool
+	}{
+		{
+			name: "same day window inside",
+			item: config.MuteSchedule{Start: "12:00", End: "14:00"},
+			t:    at(16, 13, 0),
+			want: true,
+		},
+		{
+			name: "same day window start is inclusive",
+			item: config.MuteSchedule{Start: "12:00", End: "14:00"},
+			t:    at(16, 12, 0),
+			want: true,
+		},
+		{
+			name: "same day window end is exclusive",
+			item: config.MuteSchedule{Start: "12:00", End: "14:00"},
+			t:    at(16, 14, 0),
+			want: false,
+		},
+		{
+			name: "overnight window before midnight",
+			item: config.MuteSchedule{Start: "22:00", End: "08:00"},
+			t:    at(16, 23, 30),
+			want: true,
+		},
+		{
+			name: "overnight window after midnight",
+			item: config.MuteSchedule{Start: "22:00", End: "08:00"},
+			t:    at(17, 7, 59),
+			want: true,
+		},
+		{
+			name: "overnight window outside",
+			item: config.MuteSchedule{Start: "22:00", End: "08:00"},
+			t:    at(17, 12, 0),
+			want: false,
+		},
+		{
+			name: "weekday matches the day the window starts",
+			item: config.MuteSchedule{Start: "22:00", End: "08:00", Weekdays: []string{"fri"}},
+			t:    at(17, 3, 0),
+			want: true,
+		},
+		{
+			name: "weekday of the previous night does not match",
+			item: config.MuteSchedule{Start: "22:00", End: "08:00", Weekdays: []string{"sat"}},
+			t:    at(17, 3, 0),
+			want: false,
+		},
+		{
+			name: "weekday on the start day",
+			item: config.MuteSchedule{Start: "22:00", End: "08:00", Weekdays: []string{"sat"}},
+			t:    at(17, 23, 0),
+			want: true,
+		},
+		{
+			name: "equal start and end covers the whole day",
+			item: config.MuteSchedule{Start: "09:00", End: "09:00"},
+			t:    at(16, 3, 0),
+			want: true,
+		},
+		{
+			name: "timezone of the window is used",
+			item: config.MuteSchedule{Start: "22:00", End: "08:00", Timezone: "UTC"},
+			// 上海 10:00 为 UTC 02:00
+			t:    at(16, 10, 0),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.item.Timezone == "" {
+				tt.item.Timezone = "Asia/Shanghai"
+			}
+			schedule, err := parseMuteSchedule("mute.schedules[0]", tt.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.activeAt(tt.t); got != tt.want {
+				t.Errorf("activeAt(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMuteScheduleAppliesTo(t *testing.T) {
+	tests := []struct {
+		name         string
+		destinations []string
+		want         bool
+	}{
+		{name: "all destinations", want: true},
+		{name: "listed destination", destinations: []string{"qq-group"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseMuteSchedule("mute.schedules[0]", config.MuteSchedule{
+				Destinations: tt.destinations,
+				Start:        "22:00",
+				End:          "08:00",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.appliesTo(message_box_enum.DestinationQQGroup); got != tt.want {
+				t.Errorf("appliesTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchSilence(t *testing.T) {
+	labels := map[string]string{
+		"source":      "eo",
+		"event_type":  "deployment.failed",
+		"repo_branch": "release/1.2",
+	}
+
+	tests := []struct {
+		name     string
+		matchers []model.SilenceMatcher
+		want     bool
+	}{
+		{
+			name: "no matchers",
+			want: true,
+		},
+		{
+			name:     "equal value",
+			matchers: []model.SilenceMatcher{{Name: "source", Value: "eo"}},
+			want:     true,
+		},
+		{
+			name:     "different value",
+			matchers: []model.SilenceMatcher{{Name: "source", Value: "api"}},
+			want:     false,
+		},
+		{
+			name:     "missing label matches empty value",
+			matchers: []model.SilenceMatcher{{Name: "project_id", Value: ""}},
+			want:     true,
+		},
+		{
+			name: "all matchers must match",
+			matchers: []model.SilenceMatcher{
+				{Name: "source", Value: "eo"},
+				{Name: "event_type", Value: "deployment.succeeded"},
+			},
+			want: false,
+		},
+		{
+			name:     "regex matches the whole value",
+			matchers: []model.SilenceMatcher{{Name: "repo_branch", Value: "release/.*", IsRegex: true}},
+			want:     true,
+		},
+		{
+			name:     "regex does not match a substring",
+			matchers: []model.SilenceMatcher{{Name: "event_type", Value: "failed", IsRegex: true}},
+			want:     false,
+		},
+		{
+			name:     "invalid regex never matches",
+			matchers: []model.SilenceMatcher{{Name: "source", Value: "(", IsRegex: true}},
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchSilence(tt.matchers, labels); got != tt.want {
+				t.Errorf("matchSilence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
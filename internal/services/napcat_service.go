@@ -2,11 +2,17 @@ package services
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"message-pocket/internal/config"
+	"message-pocket/internal/metrics"
+	"message-pocket/internal/tracing"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/samber/do/v2"
+	"go.opentelemetry.io/otel/trace"
 	"resty.dev/v3"
 )
 
@@ -20,60 +26,135 @@ type Response[T any] struct {
 	Stream  string `json:"stream"`
 }
 
-// NapCatService NapCat 服务
+// NapCatError NapCat 接口返回的错误，HTTPStatus 和 Retcode 会记录到发送历史
+type NapCatError struct {
+	HTTPStatus int
+	Retcode    int
+	Message    string
+}
+
+func (e *NapCatError) Error() string {
+	if e.HTTPStatus >= http.StatusBadRequest {
+		return fmt.Sprintf("status code: %d, body: %s", e.HTTPStatus, e.Message)
+	}
+	return fmt.Sprintf("NapCat API error: %s (retcode: %d)", e.Message, e.Retcode)
+}
+
+// postResult NapCat 接口调用成功时的响应
+type postResult struct {
+	Data       any
+	HTTPStatus int
+	Retcode    int
+}
+
+// SendGroupMessageResult 群消息发送结果
+type SendGroupMessageResult struct {
+	// MessageID NapCat 生成的消息 ID
+	MessageID  int64
+	HTTPStatus int
+	Retcode    int
+}
+
+// defaultNapCatTimeout 调用 NapCat 接口的默认超时
+const defaultNapCatTimeout = 10 * time.Second
+
+// NapCatService NapCat 服务，地址、令牌和超时每次请求时从当前配置读取，热加载后立即生效
 type NapCatService struct {
-	token  string
-	apiURL string
+	metrics *metrics.Metrics
+	// client 复用连接，超时按请求设置，无响应的 NapCat 不会阻塞 webhook 请求和定时任务，并计入熔断
+	client *resty.Client
 }
 
 // NewNapCatService 创建 NapCat 服务实例
-func NewNapCatService(cfg *config.Config) *NapCatService {
+func NewNapCatService(metrics *metrics.Metrics) *NapCatService {
 	return &NapCatService{
-		token:  cfg.NapCatConfig.Token,
-		apiURL: cfg.NapCatConfig.URL,
+		metrics: metrics,
+		client:  resty.New(),
 	}
 }
 
 func ProvideNapCatService(i do.Injector) (*NapCatService, error) {
-	cfg := do.MustInvoke[*config.Config](i)
-	return NewNapCatService(cfg), nil
+	metrics := do.MustInvoke[*metrics.Metrics](i)
+	return NewNapCatService(metrics), nil
+}
+
+// Shutdown 关闭 NapCat 的连接，退出时由 injector 调用
+func (s *NapCatService) Shutdown() error {
+	return s.client.Close()
+}
+
+// Bot 返回发送使用的机器人，即 NapCat 的地址
+func (s *NapCatService) Bot() string {
+	apiURL := config.GetConfig().NapCatConfig.URL
+	if parsed, err := url.Parse(apiURL); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return apiURL
 }
 
 // getURL 构建完整的 API URL
 func (s *NapCatService) getURL(endpoint string) string {
-	return fmt.Sprintf("%s/%s", s.apiURL, endpoint)
+	return fmt.Sprintf("%s/%s", config.GetConfig().NapCatConfig.URL, endpoint)
+}
+
+// timeout 返回当前配置的超时，零值使用默认值
+func (s *NapCatService) timeout() time.Duration {
+	if timeout := config.GetConfig().NapCatConfig.Timeout; timeout > 0 {
+		return timeout
+	}
+	return defaultNapCatTimeout
+}
+
+// post 发送 POST 请求到 NapCat API，记录接口耗时
+func (s *NapCatService) post(ctx context.Context, endpoint string, body any) (*postResult, error) {
+	ctx, span := tracing.Start(ctx, "NapCatService."+endpoint, trace.WithSpanKind(trace.SpanKindClient))
+
+	startedAt := time.Now()
+	result, err := s.doPost(ctx, endpoint, body)
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	s.metrics.ObserveNapCat(endpoint, outcome, time.Since(startedAt))
+
+	tracing.End(span, err)
+	return result, err
 }
 
-// post 发送 POST 请求到 NapCat API
-func (s *NapCatService) post(ctx context.Context, endpoint string, body any) (*any, error) {
-	url := s.getURL(endpoint)
+func (s *NapCatService) doPost(ctx context.Context, endpoint string, body any) (*postResult, error) {
+	apiURL := s.getURL(endpoint)
+
+	// 将当前 trace 通过 traceparent 传递给 NapCat
+	header := make(http.Header)
+	tracing.Inject(ctx, header)
 
-	client := resty.New()
 	responseData := Response[any]{}
-	response, err := client.R().
+	response, err := s.client.R().
 		SetContext(ctx).
+		SetTimeout(s.timeout()).
+		SetHeaderMultiValues(header).
 		SetHeader("Content-Type", "application/json").
-		SetHeader("Authorization", fmt.Sprintf("Bearer %s", s.token)).
+		SetHeader("Authorization", fmt.Sprintf("Bearer %s", config.GetConfig().NapCatConfig.Token)).
 		SetBody(body).
 		SetResult(&responseData).
-		Post(url)
+		Post(apiURL)
 	if err != nil {
 		return nil, err
 	}
 	if response.IsError() {
-		return nil, errors.New(fmt.Sprintf("status code: %d, body: %s", response.StatusCode(), responseData.Message))
+		return nil, &NapCatError{HTTPStatus: response.StatusCode(), Retcode: responseData.Retcode, Message: responseData.Message}
 	}
 
 	// 检查 API 状态
 	if responseData.Status != "ok" {
-		return nil, fmt.Errorf("NapCat API error: %s (retcode: %d)", responseData.Message, responseData.Retcode)
+		return nil, &NapCatError{HTTPStatus: response.StatusCode(), Retcode: responseData.Retcode, Message: responseData.Message}
 	}
 
-	return &responseData.Data, nil
+	return &postResult{Data: responseData.Data, HTTPStatus: response.StatusCode(), Retcode: responseData.Retcode}, nil
 }
 
-// SendGroupMessage 发送群消息
-func (s *NapCatService) SendGroupMessage(ctx context.Context, groupID, message string) error {
+// SendGroupMessage 发送群消息，返回 NapCat 生成的消息 ID 和响应状态
+func (s *NapCatService) SendGroupMessage(ctx context.Context, groupID, message string) (*SendGroupMessageResult, error) {
 	type SendGroupMsgRequest struct {
 		GroupID string `json:"group_id"`
 		Message string `json:"message"`
@@ -88,6 +169,45 @@ func (s *NapCatService) SendGroupMessage(ctx context.Context, groupID, message s
 		Message: message,
 	}
 
-	_, err := s.post(ctx, "send_group_msg", req)
-	return err
+	result, err := s.post(ctx, "send_group_msg", req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 回执解析失败不影响发送结果
+	var resp SendGroupMsgResponse
+	if raw, err := json.Marshal(result.Data); err == nil {
+		_ = json.Unmarshal(raw, &resp)
+	}
+	return &SendGroupMessageResult{
+		MessageID:  resp.MessageID,
+		HTTPStatus: result.HTTPStatus,
+		Retcode:    result.Retcode,
+	}, nil
+}
+
+// NapCatStatus get_status 返回的机器人状态
+type NapCatStatus struct {
+	// Online QQ 是否已登录并在线
+	Online bool `json:"online"`
+	// Good 机器人状态是否正常
+	Good bool `json:"good"`
+}
+
+// GetStatus 查询机器人的登录和在线状态
+func (s *NapCatService) GetStatus(ctx context.Context) (*NapCatStatus, error) {
+	result, err := s.post(ctx, "get_status", map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	var status NapCatStatus
+	raw, err := json.Marshal(result.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse get_status response: %w", err)
+	}
+	return &status, nil
 }
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/do/v2"
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited 令牌不足，消息需要延后发送
+var ErrRateLimited = errors.New("rate limited, message deferred")
+
+// defaultRateLimits 未配置时使用的默认限流规则，避免 QQ 机器人因发送过快被风控
+var defaultRateLimits = map[message_box_enum.DestinationType]config.RateLimitConfig{
+	message_box_enum.DestinationQQGroup: {
+		Rate:      1,
+		Burst:     5,
+		MaxWait:   5 * time.Second,
+		PerTarget: config.RateLimitRule{Rate: 0.5, Burst: 3},
+	},
+}
+
+// RateLimitService 按目的地和发送目标限流，webhook 与重试任务共享同一份令牌桶
+type RateLimitService struct {
+	rules atomic.Pointer[map[message_box_enum.DestinationType]config.RateLimitConfig]
+
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+// rateLimiter 令牌桶及创建它的规则，规则变化时重新创建
+type rateLimiter struct {
+	limiter *rate.Limiter
+	rule    config.RateLimitRule
+}
+
+// NewRateLimitService 创建限流服务实例
+func NewRateLimitService(cfg *config.Config) *RateLimitService {
+	s := &RateLimitService{
+		limiters: make(map[string]*rateLimiter),
+	}
+	rules := parseRateLimits(cfg)
+	s.rules.Store(&rules)
+	return s
+}
+
+func ProvideRateLimitService(i do.Injector) (*RateLimitService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	return NewRateLimitService(cfg), nil
+}
+
+// Reload 使用新配置的限流规则，规则未变化的令牌桶保留，以免每次保存配置都重置发送节奏；
+// 规则变化的令牌桶在下次使用时按新规则重新创建
+func (s *RateLimitService) Reload(cfg *config.Config) {
+	rules := parseRateLimits(cfg)
+	s.rules.Store(&rules)
+}
+
+// parseRateLimits 合并默认规则与配置的规则，忽略未知的目的地
+func parseRateLimits(cfg *config.Config) map[message_box_enum.DestinationType]config.RateLimitConfig {
+	rules := make(map[message_box_enum.DestinationType]config.RateLimitConfig, len(defaultRateLimits))
+	for destination, rule := range defaultRateLimits {
+		rules[destination] = rule
+	}
+	for name, rule := range cfg.RateLimit {
+		if destination, ok := message_box_enum.ParseDestinationType(name); ok {
+			rules[destination] = rule
+		}
+	}
+	return rules
+}
+
+// Wait 为一次发送获取目的地和目标两级令牌
+// 等待时长不超过 MaxWait 时阻塞等待以控制发送节奏，否则返回 ErrRateLimited
+func (s *RateLimitService) Wait(ctx context.Context, destination message_box_enum.DestinationType, target string) error {
+	return s.reserve(ctx, destination, target, true)
+}
+
+// Allow 为一次发送获取目的地和目标两级令牌，令牌不足时不等待，直接返回 ErrRateLimited。
+// 用于重试任务，避免一次执行因逐条等待而超过执行间隔
+func (s *RateLimitService) Allow(destination message_box_enum.DestinationType, target string) error {
+	return s.reserve(context.Background(), destination, target, false)
+}
+
+// reserve 预留两级令牌，wait 为 false 或等待时长超过 MaxWait 时取消预留并返回 ErrRateLimited
+func (s *RateLimitService) reserve(ctx context.Context, destination message_box_enum.DestinationType, target string, wait bool) error {
+	rule, ok := (*s.rules.Load())[destination]
+	if !ok {
+		return nil
+	}
+
+	targetRule := rule.PerTarget
+	if override, ok := rule.Targets[target]; ok {
+		targetRule = override
+	}
+
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, 2)
+	cancelAll := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+
+	var delay time.Duration
+	for _, item := range []struct {
+		key  string
+		rule config.RateLimitRule
+	}{
+		{key: destination.String(), rule: config.RateLimitRule{Rate: rule.Rate, Burst: rule.Burst}},
+		{key: fmt.Sprintf("%s:%s", destination, target), rule: targetRule},
+	} {
+		limiter := s.getLimiter(item.key, item.rule)
+		if limiter == nil {
+			continue
+		}
+		r := limiter.ReserveN(now, 1)
+		if !r.OK() {
+			cancelAll()
+			return ErrRateLimited
+		}
+		reservations = append(reservations, r)
+		delay = max(delay, r.DelayFrom(now))
+	}
+
+	if delay == 0 {
+		return nil
+	}
+	if !wait || delay > rule.MaxWait {
+		cancelAll()
+		return ErrRateLimited
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		cancelAll()
+		return ctx.Err()
+	}
+}
+
+// getLimiter 获取或创建令牌桶，规则未启用时返回 nil
+func (s *RateLimitService) getLimiter(key string, rule config.RateLimitRule) *rate.Limiter {
+	if rule.Rate <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.limiters[key]
+	if !ok || entry.rule != rule {
+		entry = &rateLimiter{
+			limiter: rate.NewLimiter(rate.Limit(rule.Rate), max(rule.Burst, 1)),
+			rule:    rule,
+		}
+		s.limiters[key] = entry
+	}
+	return entry.limiter
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/constants/scheduled_message_enum"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/repo"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tools/cron"
+	"github.com/samber/do/v2"
+)
+
+// maxCronLookahead 计算下次触发时间时最多向后查找的时长
+const maxCronLookahead = 366 * 24 * time.Hour
+
+// ErrScheduleNotFound 定时消息不存在
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ScheduleService 定时消息服务，由 cron 任务每分钟触发到期的消息
+type ScheduleService struct {
+	messageBoxService *MessageBoxService
+	scheduleRepo      repo.IScheduledMessageRepo
+}
+
+// CreateScheduleRequest 创建定时消息的请求参数，SendAt 与 CronExpr 二选一
+type CreateScheduleRequest struct {
+	Message      string
+	Destinations []string
+	Severity     message_box_enum.Severity
+	SendAt       time.Time
+	CronExpr     string
+	Timezone     string
+	ExpireAfter  time.Duration
+	CreatedBy    string
+}
+
+func NewScheduleService(
+	messageBoxService *MessageBoxService,
+	scheduleRepo repo.IScheduledMessageRepo,
+) *ScheduleService {
+	return &ScheduleService{
+		messageBoxService: messageBoxService,
+		scheduleRepo:      scheduleRepo,
+	}
+}
+
+func ProvideScheduleService(i do.Injector) (*ScheduleService, error) {
+	messageBoxService := do.MustInvoke[*MessageBoxService](i)
+	scheduleRepo := do.MustInvoke[repo.IScheduledMessageRepo](i)
+	return NewScheduleService(messageBoxService, scheduleRepo), nil
+}
+
+// CreateSchedule 创建一次性或周期性的定时消息
+func (s *ScheduleService) CreateSchedule(ctx context.Context, req CreateScheduleRequest) (*model.ScheduledMessageModel, error) {
+	if req.Message == "" {
+		return nil, errors.New("message is required")
+	}
+	if len(req.Destinations) == 0 {
+		return nil, errors.New("at least one destination is required")
+	}
+	for _, name := range req.Destinations {
+		if _, ok := message_box_enum.ParseDestinationType(name); !ok {
+			return nil, fmt.Errorf("unknown destination %q", name)
+		}
+	}
+	if req.ExpireAfter < 0 {
+		return nil, errors.New("expire_after must not be negative")
+	}
+
+	now := time.Now()
+	var nextRunAt time.Time
+	switch {
+	case req.CronExpr != "" && !req.SendAt.IsZero():
+		return nil, errors.New("send_at and cron cannot be used together")
+	case req.CronExpr != "":
+		next, err := nextCronTime(req.CronExpr, req.Timezone, now)
+		if err != nil {
+			return nil, err
+		}
+		nextRunAt = next
+	case !req.SendAt.IsZero():
+		if !req.SendAt.After(now) {
+			return nil, errors.New("send_at must be in the future")
+		}
+		nextRunAt = req.SendAt
+	default:
+		return nil, errors.New("send_at or cron is required")
+	}
+
+	severity := req.Severity
+	if severity == 0 {
+		severity = message_box_enum.SeverityInfo
+	}
+
+	return s.scheduleRepo.Create(ctx, repo.CreateScheduledMessageIn{
+		Message:      req.Message,
+		Destinations: req.Destinations,
+		Severity:     severity,
+		CronExpr:     req.CronExpr,
+		Timezone:     req.Timezone,
+		ExpireAfter:  req.ExpireAfter,
+		NextRunAt:    nextRunAt,
+		CreatedBy:    req.CreatedBy,
+	})
+}
+
+// ListSchedules 按状态查询定时消息
+func (s *ScheduleService) ListSchedules(ctx context.Context, status scheduled_message_enum.StatusType) ([]*model.ScheduledMessageModel, error) {
+	return s.scheduleRepo.ListByStatus(ctx, status)
+}
+
+// CancelSchedule 取消定时消息，已生成的消息不受影响；不存在时返回 ErrScheduleNotFound
+func (s *ScheduleService) CancelSchedule(ctx context.Context, scheduleID int32) error {
+	schedule, err := s.scheduleRepo.GetByID(ctx, scheduleID)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if schedule == nil {
+		return ErrScheduleNotFound
+	}
+
+	return s.scheduleRepo.UpdateByID(ctx, scheduleID, map[string]any{
+		"status": scheduled_message_enum.Cancelled,
+	})
+}
+
+// FireDue 触发所有到期的定时消息
+func (s *ScheduleService) FireDue(ctx context.Context) error {
+	now := time.Now()
+	schedules, err := s.scheduleRepo.ListDue(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to list due schedules: %w", err)
+	}
+
+	for _, schedule := range schedules {
+		data := map[string]any{
+			"last_run_at": now.Unix(),
+		}
+		if schedule.CronExpr == "" {
+			data["status"] = scheduled_message_enum.Finished
+		} else if next, err := nextCronTime(schedule.CronExpr, schedule.Timezone, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to compute next run of schedule, finishing it",
+				"err", err,
+				"schedule_id", schedule.ID)
+			data["status"] = scheduled_message_enum.Finished
+		} else {
+			data["next_run_at"] = next.Unix()
+		}
+
+		// 先认领再发送：发送可能因限流等待或目的地缓慢而超过一分钟，下一次任务不会再取到同一次触发。
+		// 认领后发送前进程退出会错过本次触发，已保存的消息仍由重试任务发送
+		claimed, err := s.scheduleRepo.Claim(ctx, schedule.ID, schedule.NextRunAt, data)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim schedule",
+				"err", err,
+				"schedule_id", schedule.ID)
+			continue
+		}
+		if !claimed {
+			slog.InfoContext(ctx, "Schedule already claimed or cancelled, skipped",
+				"schedule_id", schedule.ID,
+				"next_run_at", schedule.NextRunAt)
+			continue
+		}
+
+		s.fire(ctx, schedule, now)
+	}
+
+	return nil
+}
+
+// fire 向定时消息的每个目的地发送一条消息，发送失败的由重试任务负责
+func (s *ScheduleService) fire(ctx context.Context, schedule *model.ScheduledMessageModel, now time.Time) {
+	var expiresAt time.Time
+	if schedule.ExpireAfter > 0 {
+		expiresAt = time.Unix(schedule.NextRunAt+schedule.ExpireAfter, 0)
+		// 服务停机等原因错过了有效期，本次不再发送
+		if now.After(expiresAt) {
+			slog.WarnContext(ctx, "Schedule missed its delivery window, skipped",
+				"schedule_id", schedule.ID,
+				"next_run_at", schedule.NextRunAt)
+			return
+		}
+	}
+
+	for _, name := range schedule.Destinations {
+		destination, ok := message_box_enum.ParseDestinationType(name)
+		if !ok {
+			slog.ErrorContext(ctx, "Unknown destination in schedule",
+				"schedule_id", schedule.ID,
+				"destination", name)
+			continue
+		}
+
+		bizID := fmt.Sprintf("schedule-%d-%d", schedule.ID, schedule.NextRunAt)
+		_, err := s.messageBoxService.SaveAndSendMessage(ctx, SaveMessageRequest{
+			BizID:           bizID,
+			Message:         schedule.Message,
+			SourceRequest:   fmt.Sprintf(`{"schedule_id":%d}`, schedule.ID),
+			SourceType:      message_box_enum.SourceTypeSchedule,
+			DestinationType: destination,
+			Severity:        schedule.Severity,
+			ExpiresAt:       expiresAt,
+			// 同一次触发的同一目的地只保存一条
+			IdempotencyKey: bizID + "/" + destination.String(),
+		})
+		if errors.Is(err, repo.ErrDuplicateMessage) {
+			slog.InfoContext(ctx, "Scheduled message already fired, skipped",
+				"schedule_id", schedule.ID,
+				"destination", name)
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to send scheduled message",
+				"err", err,
+				"schedule_id", schedule.ID,
+				"destination", name)
+			continue
+		}
+
+		slog.InfoContext(ctx, "Successfully fired scheduled message",
+			"schedule_id", schedule.ID,
+			"destination", name)
+	}
+}
+
+// nextCronTime 计算 cron 表达式在 after 之后的下一次触发时间（精确到分钟）。
+// 按月、日、时、分逐级跳过不匹配的值，不逐分钟扫描，最多向后查找 maxCronLookahead
+func nextCronTime(expr, timezone string, after time.Time) (time.Time, error) {
+	schedule, err := cron.NewSchedule(expr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	location := time.UTC
+	if timezone != "" {
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return time.Time{}, fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+
+	matches := func(set map[int]struct{}, v int) bool {
+		_, ok := set[v]
+		return ok
+	}
+
+	// 用 time.Date 进位而非加固定时长，时区偏移不是整小时或遇到夏令时切换时也按本地时间对齐
+	t := after.In(location).Truncate(time.Minute).Add(time.Minute)
+	deadline := t.Add(maxCronLookahead)
+	for t.Before(deadline) {
+		year, month, day := t.Date()
+		switch {
+		case !matches(schedule.Months, int(month)):
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+		case !matches(schedule.Days, day) || !matches(schedule.DaysOfWeek, int(t.Weekday())):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, location)
+		case !matches(schedule.Hours, t.Hour()):
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, location)
+		case !matches(schedule.Minutes, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cron expression %q never fires within a year", expr)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextCronTime(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-03-07 是星期六，上海时间 18:17:30
+	after := time.Date(2026, time.March, 7, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expr     string
+		timezone string
+		want     time.Time
+		wantErr  string
+	}{
+		{
+			name:     "every minute",
+			expr:     "* * * * *",
+			timezone: "Asia/Shanghai",
+			want:     time.Date(2026, time.March, 7, 18, 18, 0, 0, shanghai),
+		},
+		{
+			name: "defaults to utc",
+			expr: "0 12 * * *",
+			want: time.Date(2026, time.March, 7, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekly",
+			expr:     "30 9 * * 1",
+			timezone: "Asia/Shanghai",
+			want:     time.Date(2026, time.March, 9, 9, 30, 0, 0, shanghai),
+		},
+		{
+			name:     "next day range",
+			expr:     "*/15 8-9 * * *",
+			timezone: "Asia/Shanghai",
+			want:     time.Date(2026, time.March, 8, 8, 0, 0, 0, shanghai),
+		},
+		{
+			name:     "day of month and day of week",
+			expr:     "0 12 13 * 5",
+			timezone: "Asia/Shanghai",
+			want:     time.Date(2026, time.March, 13, 12, 0, 0, 0, shanghai),
+		},
+		{
+			name:     "yearly",
+			expr:     "0 0 1 1 *",
+			timezone: "Asia/Shanghai",
+			want:     time.Date(2027, time.January, 1, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name:    "never fires",
+			expr:    "0 0 31 2 *",
+			wantErr: "never fires within a year",
+		},
+		{
+			name:    "invalid expression",
+			expr:    "61 * * * *",
+			wantErr: "invalid cron expression",
+		},
+		{
+			name:     "invalid timezone",
+			expr:     "* * * * *",
+			timezone: "Mars/Olympus",
+			wantErr:  "invalid timezone",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextCronTime(tt.expr, tt.timezone, after)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("nextCronTime() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nextCronTime() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextCronTime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/services/logic"
+	"sync/atomic"
+
+	"github.com/samber/do/v2"
+	"github.com/samber/lo"
+)
+
+// defaultSeverityResolvers 各来源内置的默认严重级别映射
+var defaultSeverityResolvers = map[message_box_enum.SourceType]func(eventType string) message_box_enum.Severity{
+	message_box_enum.SourceTypeEO: logic.GetEventSeverity,
+}
+
+// severityRule 解析后的严重级别映射规则
+type severityRule struct {
+	source     message_box_enum.SourceType
+	eventTypes []string
+	severity   message_box_enum.Severity
+}
+
+// SeverityService 根据来源和事件类型确定消息严重级别
+type SeverityService struct {
+	rules atomic.Pointer[[]severityRule]
+}
+
+// NewSeverityService 创建严重级别服务实例
+func NewSeverityService(cfg *config.Config) (*SeverityService, error) {
+	rules, err := parseSeverityRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SeverityService{}
+	s.rules.Store(&rules)
+	return s, nil
+}
+
+func ProvideSeverityService(i do.Injector) (*SeverityService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	return NewSeverityService(cfg)
+}
+
+// Reload 使用新配置的规则，解析失败时保留原规则
+func (s *SeverityService) Reload(cfg *config.Config) error {
+	rules, err := parseSeverityRules(cfg)
+	if err != nil {
+		return err
+	}
+	s.rules.Store(&rules)
+	return nil
+}
+
+// parseSeverityRules 解析严重级别映射规则，返回全部规则的错误
+func parseSeverityRules(cfg *config.Config) ([]severityRule, error) {
+	v := &config.Validator{}
+	rules := make([]severityRule, 0, len(cfg.Severity))
+	for idx, item := range cfg.Severity {
+		source, sourceOK := message_box_enum.ParseSourceType(item.Source)
+		if !sourceOK {
+			v.Add(fmt.Sprintf("severity[%d].source", idx), "unknown source %q", item.Source)
+		}
+		severity, severityOK := message_box_enum.ParseSeverity(item.Severity)
+		if !severityOK {
+			v.Add(fmt.Sprintf("severity[%d].severity", idx), "unknown severity %q", item.Severity)
+		}
+		if !sourceOK || !severityOK {
+			continue
+		}
+		rules = append(rules, severityRule{
+			source:     source,
+			eventTypes: item.EventTypes,
+			severity:   severity,
+		})
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Resolve 按配置规则确定严重级别，未命中时使用来源的默认映射
+func (s *SeverityService) Resolve(source message_box_enum.SourceType, eventType string) message_box_enum.Severity {
+	for _, rule := range *s.rules.Load() {
+		if rule.source != source {
+			continue
+		}
+		if len(rule.eventTypes) == 0 || lo.Contains(rule.eventTypes, eventType) {
+			return rule.severity
+		}
+	}
+
+	if resolver, ok := defaultSeverityResolvers[source]; ok {
+		return resolver(eventType)
+	}
+	return message_box_enum.SeverityInfo
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/api_token_enum"
+	"message-pocket/internal/define/model"
+	"message-pocket/internal/repo"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/samber/do/v2"
+	"github.com/samber/lo"
+)
+
+const (
+	// tokenSecretPrefix 令牌明文前缀，便于在日志和代码仓库中识别泄露的令牌
+	tokenSecretPrefix = "mp_"
+	// tokenSecretLength 令牌明文随机部分的长度
+	tokenSecretLength = 40
+	// tokenDisplayLength 列表中展示的令牌前缀长度
+	tokenDisplayLength = len(tokenSecretPrefix) + 6
+	// tokenTouchInterval 最近使用时间的更新间隔，避免每次请求都写库
+	tokenTouchInterval = time.Minute
+	// openTokenName 配置文件中 server.open_token 对应的令牌名称
+	openTokenName = "open_token"
+)
+
+var (
+	// ErrTokenInvalid 令牌不存在
+	ErrTokenInvalid = errors.New("invalid token")
+	// ErrTokenExpired 令牌已过期
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenRevoked 令牌已吊销
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrTokenNotFound 按 ID 查询的令牌不存在
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// TokenService API 令牌的创建、轮换、吊销与认证
+type TokenService struct {
+	tokenRepo repo.IAPITokenRepo
+}
+
+// CreateTokenRequest 创建令牌的请求参数
+type CreateTokenRequest struct {
+	Name   string
+	Scopes []string
+	// ExpiresAt 零值表示不过期
+	ExpiresAt time.Time
+	CreatedBy string
+}
+
+// IssuedToken 新创建或轮换后的令牌，Secret 只返回这一次
+type IssuedToken struct {
+	*model.APITokenModel
+	Secret string `json:"secret"`
+}
+
+func NewTokenService(tokenRepo repo.IAPITokenRepo) *TokenService {
+	return &TokenService{
+		tokenRepo: tokenRepo,
+	}
+}
+
+func ProvideTokenService(i do.Injector) (*TokenService, error) {
+	tokenRepo := do.MustInvoke[repo.IAPITokenRepo](i)
+	return NewTokenService(tokenRepo), nil
+}
+
+// CreateToken 创建令牌，返回的明文需要由调用方妥善保存
+func (s *TokenService) CreateToken(ctx context.Context, req CreateTokenRequest) (*IssuedToken, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidArgument)
+	}
+	if req.Name == openTokenName {
+		return nil, fmt.Errorf("%w: name %q is reserved", ErrInvalidArgument, openTokenName)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidArgument)
+	}
+	for _, name := range req.Scopes {
+		if _, ok := api_token_enum.ParseScope(name); !ok {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidArgument, name)
+		}
+	}
+	if !req.ExpiresAt.IsZero() && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidArgument)
+	}
+
+	existing, err := s.tokenRepo.GetByName(ctx, req.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token name: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w: token %q already exists", ErrInvalidArgument, req.Name)
+	}
+
+	secret := newTokenSecret()
+	token, err := s.tokenRepo.Create(ctx, repo.CreateAPITokenIn{
+		Name:        req.Name,
+		TokenPrefix: secret[:tokenDisplayLength],
+		TokenHash:   hashTokenSecret(secret),
+		Scopes:      lo.Uniq(req.Scopes),
+		ExpiresAt:   req.ExpiresAt,
+		CreatedBy:   req.CreatedBy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	slog.InfoContext(ctx, "API token created", "token_id", token.ID, "name", token.Name, "scopes", token.Scopes)
+	return &IssuedToken{APITokenModel: token, Secret: secret}, nil
+}
+
+// ListTokens 查询全部令牌，不包含明文和哈希
+func (s *TokenService) ListTokens(ctx context.Context) ([]*model.APITokenModel, error) {
+	return s.tokenRepo.List(ctx)
+}
+
+// RotateToken 为令牌生成新的明文，名称、范围和有效期不变，旧明文立即失效
+func (s *TokenService) RotateToken(ctx context.Context, tokenID string) (*IssuedToken, error) {
+	token, err := s.getToken(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if token.RevokedAt > 0 {
+		return nil, fmt.Errorf("%w: token is revoked", ErrInvalidArgument)
+	}
+
+	secret := newTokenSecret()
+	token.TokenPrefix = secret[:tokenDisplayLength]
+	token.TokenHash = hashTokenSecret(secret)
+	if err := s.tokenRepo.UpdateByID(ctx, tokenID, map[string]any{
+		"token_prefix": token.TokenPrefix,
+		"token_hash":   token.TokenHash,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to rotate token: %w", err)
+	}
+
+	slog.InfoContext(ctx, "API token rotated", "token_id", token.ID, "name", token.Name)
+	return &IssuedToken{APITokenModel: token, Secret: secret}, nil
+}
+
+// RevokeToken 吊销令牌，重复吊销不报错
+func (s *TokenService) RevokeToken(ctx context.Context, tokenID string) (*model.APITokenModel, error) {
+	token, err := s.getToken(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if token.RevokedAt > 0 {
+		return token, nil
+	}
+
+	token.RevokedAt = time.Now().Unix()
+	if err := s.tokenRepo.UpdateByID(ctx, tokenID, map[string]any{
+		"revoked_at": token.RevokedAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	slog.InfoContext(ctx, "API token revoked", "token_id", token.ID, "name", token.Name)
+	return token, nil
+}
+
+// Authenticate 校验令牌明文，通过时返回令牌并按分钟粒度记录最近使用时间
+// 配置文件中的 server.open_token 仍然有效，拥有全部范围
+func (s *TokenService) Authenticate(ctx context.Context, secret string) (*model.APITokenModel, error) {
+	if secret == "" {
+		return nil, ErrTokenInvalid
+	}
+	openToken := config.GetConfig().ServerConfig.OpenToken
+	if openToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(openToken)) == 1 {
+		return &model.APITokenModel{
+			Name: openTokenName,
+			Scopes: lo.Map(api_token_enum.Scopes, func(scope api_token_enum.Scope, _ int) string {
+				return scope.String()
+			}),
+		}, nil
+	}
+
+	tokenHash := hashTokenSecret(secret)
+	token, err := s.tokenRepo.GetByHash(ctx, tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+	// 按哈希查询不泄露明文的比较时序，这里再做一次常量时间比较
+	if token == nil || subtle.ConstantTimeCompare([]byte(token.TokenHash), []byte(tokenHash)) != 1 {
+		return nil, ErrTokenInvalid
+	}
+
+	now := time.Now()
+	if token.RevokedAt > 0 {
+		return nil, ErrTokenRevoked
+	}
+	if token.ExpiresAt > 0 && now.Unix() >= token.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	if now.Sub(time.Unix(token.LastUsedAt, 0)) >= tokenTouchInterval {
+		token.LastUsedAt = now.Unix()
+		if err := s.tokenRepo.UpdateByID(ctx, token.ID, map[string]any{
+			"last_used_at": token.LastUsedAt,
+		}); err != nil {
+			// 记录失败不影响本次认证
+			slog.WarnContext(ctx, "Failed to update token last used time", "err", err, "token_id", token.ID)
+		}
+	}
+
+	return token, nil
+}
+
+func (s *TokenService) getToken(ctx context.Context, tokenID string) (*model.APITokenModel, error) {
+	token, err := s.tokenRepo.GetByID(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token == nil {
+		return nil, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+// newTokenSecret 生成令牌明文
+func newTokenSecret() string {
+	return tokenSecretPrefix + security.RandomString(tokenSecretLength)
+}
+
+// hashTokenSecret 令牌明文是高熵随机串，SHA-256 足以防止数据库泄露后被还原
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
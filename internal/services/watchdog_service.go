@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/repo"
+	"message-pocket/internal/tracing"
+	"net/http"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/samber/do/v2"
+	"resty.dev/v3"
+)
+
+// 告警类型
+const (
+	IncidentBacklog       = "backlog"
+	IncidentBacklogGrowth = "backlog_growth"
+	IncidentDeadLetter    = "dead_letter"
+	IncidentCircuitOpen   = "circuit_open"
+)
+
+// 告警状态
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// 告警渠道
+const (
+	alertChannelEmail   = "email"
+	alertChannelWebhook = "webhook"
+)
+
+// watchdogSettings 一次检查使用的配置，检查开始时取出，之后不再受热加载影响
+type watchdogSettings struct {
+	cfg     config.WatchdogConfig
+	emailTo []mail.Address
+}
+
+// webhookTimeout 告警 webhook 的请求超时，避免无响应的地址阻塞后续检查
+const webhookTimeout = 10 * time.Second
+
+// defaultWatchdog 未配置或配置为零值时使用的检查规则
+var defaultWatchdog = config.WatchdogConfig{
+	BacklogThreshold:      100,
+	BacklogGrowthChecks:   10,
+	DeadLetterQuietPeriod: 30 * time.Minute,
+}
+
+// Alert 告警内容，webhook 以该结构的 JSON 发送
+type Alert struct {
+	Status string `json:"status"`
+	// Incident 告警的唯一标识，同一问题持续期间只告警一次，恢复时以相同标识发送恢复通知
+	Incident    string `json:"incident"`
+	Kind        string `json:"kind"`
+	Destination string `json:"destination"`
+	Target      string `json:"target,omitempty"`
+	Summary     string `json:"summary"`
+	StartedAt   int64  `json:"started_at"`
+	ResolvedAt  int64  `json:"resolved_at,omitempty"`
+}
+
+// incident 进行中的告警，按渠道记录发送结果，下次检查时只向发送失败的渠道重发
+type incident struct {
+	alert *Alert
+	// fired 已成功发送告警的渠道
+	fired map[string]bool
+	// resolvedAt 首次检测到恢复的时间，为 0 表示问题仍存在
+	resolvedAt int64
+	// resolved 已成功发送恢复通知的渠道
+	resolved map[string]bool
+}
+
+// WatchdogService 定时检查发送积压、死信和熔断，通过邮件或 webhook 等不经过消息目的地的备用渠道告警
+// 告警状态只保存在内存中，重启后仍存在的问题会再告警一次
+type WatchdogService struct {
+	app            core.App
+	messageBoxRepo repo.IMessageBoxRepo
+	circuitBreaker *CircuitBreakerService
+	webhookClient  *resty.Client
+
+	// mu 保护配置，只在读取和替换配置时持有，检查和发送告警时不持有，避免阻塞热加载
+	mu      sync.Mutex
+	cfg     config.WatchdogConfig
+	emailTo []mail.Address
+
+	// checkMu 保证同一时间只有一次检查，保护以下检查状态
+	checkMu   sync.Mutex
+	incidents map[string]*incident
+	// pendingHistory 最近几次检查的待发送消息数
+	pendingHistory map[message_box_enum.DestinationType][]int64
+	// deadLetterCounts 上次检查时的死信数，首次检查只记录基线，不对历史死信告警
+	deadLetterCounts map[message_box_enum.DestinationType]int64
+	lastDeadLetterAt map[message_box_enum.DestinationType]time.Time
+}
+
+// NewWatchdogService 创建自监控服务实例，启用时需要配置至少一个告警渠道
+func NewWatchdogService(app core.App, cfg *config.Config, messageBoxRepo repo.IMessageBoxRepo, circuitBreaker *CircuitBreakerService) (*WatchdogService, error) {
+	watchdog, emailTo, err := parseWatchdog(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WatchdogService{
+		app:              app,
+		cfg:              watchdog,
+		emailTo:          emailTo,
+		messageBoxRepo:   messageBoxRepo,
+		circuitBreaker:   circuitBreaker,
+		webhookClient:    resty.New().SetTimeout(webhookTimeout),
+		incidents:        make(map[string]*incident),
+		pendingHistory:   make(map[message_box_enum.DestinationType][]int64),
+		lastDeadLetterAt: make(map[message_box_enum.DestinationType]time.Time),
+	}, nil
+}
+
+func ProvideWatchdogService(i do.Injector) (*WatchdogService, error) {
+	app := do.MustInvoke[core.App](i)
+	cfg := do.MustInvoke[*config.Config](i)
+	messageBoxRepo := do.MustInvoke[repo.IMessageBoxRepo](i)
+	circuitBreaker := do.MustInvoke[*CircuitBreakerService](i)
+	return NewWatchdogService(app, cfg, messageBoxRepo, circuitBreaker)
+}
+
+// Shutdown 关闭告警 webhook 的连接，退出时由 injector 调用
+func (s *WatchdogService) Shutdown() error {
+	return s.webhookClient.Close()
+}
+
+// Reload 使用新配置的检查规则和告警渠道，进行中的告警保留
+func (s *WatchdogService) Reload(cfg *config.Config) error {
+	watchdog, emailTo, err := parseWatchdog(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = watchdog
+	s.emailTo = emailTo
+	return nil
+}
+
+// parseWatchdog 解析检查规则和告警收件人，启用时需要配置至少一个告警渠道
+func parseWatchdog(cfg *config.Config) (config.WatchdogConfig, []mail.Address, error) {
+	watchdog := withWatchdogDefaults(cfg.Watchdog)
+
+	v := &config.Validator{}
+	emailTo := make([]mail.Address, 0, len(watchdog.Email.To))
+	for idx, to := range watchdog.Email.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			v.Add(fmt.Sprintf("watchdog.email.to[%d]", idx), "invalid address %q", to)
+			continue
+		}
+		emailTo = append(emailTo, *address)
+	}
+	if watchdog.Enabled && len(watchdog.Email.To) == 0 && watchdog.Webhook.URL == "" {
+		v.Add("watchdog", "email.to or webhook.url is required when enabled")
+	}
+	if err := v.Err(); err != nil {
+		return watchdog, nil, err
+	}
+	return watchdog, emailTo, nil
+}
+
+// withWatchdogDefaults 零值字段使用默认值
+func withWatchdogDefaults(cfg config.WatchdogConfig) config.WatchdogConfig {
+	if cfg.BacklogThreshold == 0 {
+		cfg.BacklogThreshold = defaultWatchdog.BacklogThreshold
+	}
+	if cfg.BacklogGrowthChecks == 0 {
+		cfg.BacklogGrowthChecks = defaultWatchdog.BacklogGrowthChecks
+	}
+	if cfg.DeadLetterQuietPeriod <= 0 {
+		cfg.DeadLetterQuietPeriod = defaultWatchdog.DeadLetterQuietPeriod
+	}
+	return cfg
+}
+
+// Check 检查一次发送状况，新出现的问题发送告警，已恢复的问题发送恢复通知
+func (s *WatchdogService) Check(ctx context.Context) error {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	settings := s.settings()
+	if !settings.cfg.Enabled {
+		return nil
+	}
+
+	counts, err := s.messageBoxRepo.CountByStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count messages: %w", err)
+	}
+	pending := make(map[message_box_enum.DestinationType]int64)
+	// 超过有效期和达到最多发送次数的消息都不再重试，一起按死信统计
+	deadLetter := make(map[message_box_enum.DestinationType]int64)
+	for _, count := range counts {
+		switch count.Status {
+		case message_box_enum.Pending:
+			pending[count.DestinationType] = count.Count
+		case message_box_enum.Expired, message_box_enum.DeadLetter:
+			deadLetter[count.DestinationType] += count.Count
+		}
+	}
+
+	now := time.Now()
+	detected := make(map[string]*Alert)
+	for _, destination := range message_box_enum.DestinationTypes() {
+		for _, alert := range s.detect(settings.cfg, destination, pending[destination], deadLetter[destination], now) {
+			detected[alert.Incident] = alert
+		}
+	}
+	if s.deadLetterCounts == nil {
+		s.deadLetterCounts = deadLetter
+	}
+
+	return s.reconcile(ctx, settings, detected, now)
+}
+
+// settings 取出当前配置
+func (s *WatchdogService) settings() watchdogSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return watchdogSettings{cfg: s.cfg, emailTo: s.emailTo}
+}
+
+// detect 检查单个目的地的问题，调用方需持有 checkMu
+func (s *WatchdogService) detect(cfg config.WatchdogConfig, destination message_box_enum.DestinationType, pending, deadLetter int64, now time.Time) []*Alert {
+	name := destination.String()
+	alerts := make([]*Alert, 0)
+
+	if cfg.BacklogThreshold > 0 && pending >= cfg.BacklogThreshold {
+		alerts = append(alerts, &Alert{
+			Incident:    IncidentBacklog + ":" + name,
+			Kind:        IncidentBacklog,
+			Destination: name,
+			Summary:     fmt.Sprintf("%s 有 %d 条待发送消息，达到告警阈值 %d", name, pending, cfg.BacklogThreshold),
+		})
+	}
+
+	if checks := cfg.BacklogGrowthChecks; checks > 0 {
+		history := append(s.pendingHistory[destination], pending)
+		if len(history) > checks+1 {
+			history = history[len(history)-checks-1:]
+		}
+		s.pendingHistory[destination] = history
+
+		growing := len(history) == checks+1
+		for i := 1; growing && i < len(history); i++ {
+			growing = history[i] > history[i-1]
+		}
+		if growing {
+			alerts = append(alerts, &Alert{
+				Incident:    IncidentBacklogGrowth + ":" + name,
+				Kind:        IncidentBacklogGrowth,
+				Destination: name,
+				Summary:     fmt.Sprintf("%s 的待发送消息连续 %d 次检查持续增长，从 %d 条增长到 %d 条", name, checks, history[0], pending),
+			})
+		}
+	}
+
+	if s.deadLetterCounts != nil {
+		if deadLetter > s.deadLetterCounts[destination] {
+			s.lastDeadLetterAt[destination] = now
+		}
+		s.deadLetterCounts[destination] = deadLetter
+	}
+	if lastDeadLetterAt, ok := s.lastDeadLetterAt[destination]; ok && now.Sub(lastDeadLetterAt) < cfg.DeadLetterQuietPeriod {
+		alerts = append(alerts, &Alert{
+			Incident:    IncidentDeadLetter + ":" + name,
+			Kind:        IncidentDeadLetter,
+			Destination: name,
+			Summary:     fmt.Sprintf("%s 出现超过有效期或达到最多发送次数的死信消息，当前共 %d 条", name, deadLetter),
+		})
+	}
+
+	for _, circuit := range s.circuitBreaker.States(destination) {
+		if circuit.State != CircuitOpen {
+			continue
+		}
+		alerts = append(alerts, &Alert{
+			Incident:    IncidentCircuitOpen + ":" + name + ":" + circuit.Target,
+			Kind:        IncidentCircuitOpen,
+			Destination: name,
+			Target:      circuit.Target,
+			Summary:     fmt.Sprintf("%s 的发送目标 %s 连续发送失败 %d 次，已熔断", name, circuit.Target, circuit.ConsecutiveFailures),
+		})
+	}
+
+	return alerts
+}
+
+// reconcile 对比本次检查结果与进行中的告警，每个问题在每个渠道只告警一次，恢复后发送恢复通知，调用方需持有 checkMu
+func (s *WatchdogService) reconcile(ctx context.Context, settings watchdogSettings, detected map[string]*Alert, now time.Time) error {
+	var errs []error
+
+	for key, alert := range detected {
+		current, ok := s.incidents[key]
+		if !ok {
+			alert.Status = AlertFiring
+			alert.StartedAt = now.Unix()
+			current = &incident{alert: alert, fired: make(map[string]bool)}
+			s.incidents[key] = current
+			slog.WarnContext(ctx, "Watchdog incident detected", "incident", key, "summary", alert.Summary)
+		}
+		if current.resolvedAt != 0 {
+			// 恢复通知还未全部发出时问题再次出现，已收到恢复通知的渠道重新告警
+			for channel := range current.resolved {
+				delete(current.fired, channel)
+			}
+			current.resolvedAt, current.resolved = 0, nil
+		}
+		if err := s.notify(ctx, settings, current.alert, settings.channels(), current.fired); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send alert %s: %w", key, err))
+		}
+	}
+
+	for key, current := range s.incidents {
+		if _, ok := detected[key]; ok {
+			continue
+		}
+		if current.resolvedAt == 0 {
+			slog.InfoContext(ctx, "Watchdog incident resolved", "incident", key)
+			current.resolvedAt = now.Unix()
+			current.resolved = make(map[string]bool)
+		}
+		// 只向发送过告警的渠道发送恢复通知，告警未发送成功的问题已恢复，不再通知
+		channels := make([]string, 0, len(current.fired))
+		for _, channel := range settings.channels() {
+			if current.fired[channel] {
+				channels = append(channels, channel)
+			}
+		}
+		resolved := *current.alert
+		resolved.Status = AlertResolved
+		resolved.ResolvedAt = current.resolvedAt
+		if err := s.notify(ctx, settings, &resolved, channels, current.resolved); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send recovery of %s: %w", key, err))
+			continue
+		}
+		delete(s.incidents, key)
+	}
+
+	return errors.Join(errs...)
+}
+
+// channels 已配置的告警渠道
+func (settings watchdogSettings) channels() []string {
+	channels := make([]string, 0, 2)
+	if len(settings.emailTo) > 0 {
+		channels = append(channels, alertChannelEmail)
+	}
+	if settings.cfg.Webhook.URL != "" {
+		channels = append(channels, alertChannelWebhook)
+	}
+	return channels
+}
+
+// notify 通过 sent 中还没有的渠道发送告警，成功的渠道记录到 sent，全部渠道都已发送时返回 nil
+func (s *WatchdogService) notify(ctx context.Context, settings watchdogSettings, alert *Alert, channels []string, sent map[string]bool) error {
+	ctx, span := tracing.Start(ctx, "WatchdogService.notify")
+
+	var errs []error
+	for _, channel := range channels {
+		if sent[channel] {
+			continue
+		}
+		var err error
+		switch channel {
+		case alertChannelEmail:
+			err = s.sendEmail(settings.emailTo, alert)
+		case alertChannelWebhook:
+			err = s.sendWebhook(ctx, settings.cfg.Webhook, alert)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+			continue
+		}
+		sent[channel] = true
+	}
+
+	err := errors.Join(errs...)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *WatchdogService) sendEmail(to []mail.Address, alert *Alert) error {
+	meta := s.app.Settings().Meta
+	subject := fmt.Sprintf("[message-pocket] %s: %s", strings.ToUpper(alert.Status), alert.Summary)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "状态：%s\n", alert.Status)
+	fmt.Fprintf(&body, "问题：%s\n", alert.Summary)
+	fmt.Fprintf(&body, "标识：%s\n", alert.Incident)
+	fmt.Fprintf(&body, "开始时间：%s\n", time.Unix(alert.StartedAt, 0).Format(time.RFC3339))
+	if alert.ResolvedAt > 0 {
+		fmt.Fprintf(&body, "恢复时间：%s\n", time.Unix(alert.ResolvedAt, 0).Format(time.RFC3339))
+	}
+
+	return s.app.NewMailClient().Send(&mailer.Message{
+		From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+		To:      to,
+		Subject: subject,
+		Text:    body.String(),
+	})
+}
+
+func (s *WatchdogService) sendWebhook(ctx context.Context, webhook config.WatchdogWebhookConfig, alert *Alert) error {
+	header := make(http.Header)
+	tracing.Inject(ctx, header)
+
+	response, err := s.webhookClient.R().
+		SetContext(ctx).
+		SetHeaderMultiValues(header).
+		SetHeaders(webhook.Headers).
+		SetHeader("Content-Type", "application/json").
+		SetBody(alert).
+		Post(webhook.URL)
+	if err != nil {
+		return err
+	}
+	if response.IsError() {
+		return fmt.Errorf("status code: %d", response.StatusCode())
+	}
+	return nil
+}
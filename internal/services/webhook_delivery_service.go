@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"message-pocket/internal/config"
+	"message-pocket/internal/constants/message_box_enum"
+	"message-pocket/internal/repo"
+	"sync"
+	"time"
+
+	"github.com/samber/do/v2"
+)
+
+// webhookDeliveryTTL 记住投递 ID 的时长，来源在此期间的重复投递会被丢弃
+const webhookDeliveryTTL = 24 * time.Hour
+
+// defaultWebhookTolerance 事件时间与服务器时间默认允许的偏差
+const defaultWebhookTolerance = 5 * time.Minute
+
+// ErrWebhookTimestampSkewed 事件时间与服务器时间的偏差超出允许范围
+var ErrWebhookTimestampSkewed = errors.New("webhook timestamp is outside the tolerance window")
+
+// WebhookDeliveryService 记录来源 webhook 已处理的投递，丢弃来源重试等原因造成的重复投递，
+// 并拒绝事件时间偏差过大的请求
+type WebhookDeliveryService struct {
+	webhookDeliveryRepo repo.IWebhookDeliveryRepo
+
+	mu        sync.Mutex
+	tolerance time.Duration
+}
+
+// NewWebhookDeliveryService 创建 webhook 投递去重服务实例
+func NewWebhookDeliveryService(cfg *config.Config, webhookDeliveryRepo repo.IWebhookDeliveryRepo) *WebhookDeliveryService {
+	return &WebhookDeliveryService{
+		webhookDeliveryRepo: webhookDeliveryRepo,
+		tolerance:           withWebhookToleranceDefault(cfg.ServerConfig.WebhookTolerance),
+	}
+}
+
+func ProvideWebhookDeliveryService(i do.Injector) (*WebhookDeliveryService, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	webhookDeliveryRepo := do.MustInvoke[repo.IWebhookDeliveryRepo](i)
+	return NewWebhookDeliveryService(cfg, webhookDeliveryRepo), nil
+}
+
+// Reload 使用新配置允许的偏差
+func (s *WebhookDeliveryService) Reload(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tolerance = withWebhookToleranceDefault(cfg.ServerConfig.WebhookTolerance)
+}
+
+// withWebhookToleranceDefault 零值使用默认值
+func withWebhookToleranceDefault(tolerance time.Duration) time.Duration {
+	if tolerance == 0 {
+		return defaultWebhookTolerance
+	}
+	return tolerance
+}
+
+// CheckTimestamp 事件时间早于或晚于 now 超过允许的偏差时返回 ErrWebhookTimestampSkewed，偏差小于 0 表示不检查
+func (s *WebhookDeliveryService) CheckTimestamp(t time.Time, now time.Time) error {
+	s.mu.Lock()
+	tolerance := s.tolerance
+	s.mu.Unlock()
+
+	if tolerance < 0 {
+		return nil
+	}
+	if skew := now.Sub(t); skew > tolerance || skew < -tolerance {
+		return fmt.Errorf("%w: %s is %s from now", ErrWebhookTimestampSkewed, t.Format(time.RFC3339), skew.Round(time.Second))
+	}
+	return nil
+}
+
+// Remember 记录投递 ID，返回是否为首次投递；处理失败时应调用 Forget，以便来源重新投递
+func (s *WebhookDeliveryService) Remember(ctx context.Context, sourceType message_box_enum.SourceType, deliveryID string) (bool, error) {
+	return s.webhookDeliveryRepo.Remember(ctx, sourceType, deliveryID, webhookDeliveryTTL)
+}
+
+// Forget 删除投递 ID，失败只记录日志，来源在记录过期前的重新投递会被丢弃
+func (s *WebhookDeliveryService) Forget(ctx context.Context, sourceType message_box_enum.SourceType, deliveryID string) {
+	if err := s.webhookDeliveryRepo.Forget(ctx, sourceType, deliveryID); err != nil {
+		slog.WarnContext(ctx, "Failed to forget webhook delivery",
+			"err", err,
+			"source", sourceType.String(),
+			"delivery_id", deliveryID)
+	}
+}
+
+// Cleanup 清理已过期的投递 ID
+func (s *WebhookDeliveryService) Cleanup(ctx context.Context) error {
+	deleted, err := s.webhookDeliveryRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Cleaned up expired webhook deliveries", "count", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"message-pocket/internal/config"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliveryCheckTimestamp(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		tolerance time.Duration
+		t         time.Time
+		wantErr   bool
+	}{
+		{name: "now", t: now},
+		{name: "default tolerance past", t: now.Add(-5 * time.Minute)},
+		{name: "default tolerance future", t: now.Add(5 * time.Minute)},
+		{name: "too old", t: now.Add(-5*time.Minute - time.Second), wantErr: true},
+		{name: "too far in the future", t: now.Add(5*time.Minute + time.Second), wantErr: true},
+		{name: "configured tolerance", tolerance: time.Hour, t: now.Add(-30 * time.Minute)},
+		{name: "configured tolerance exceeded", tolerance: time.Minute, t: now.Add(-2 * time.Minute), wantErr: true},
+		{name: "check disabled", tolerance: -1, t: now.Add(-72 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.ServerConfig.WebhookTolerance = tt.tolerance
+			s := NewWebhookDeliveryService(cfg, nil)
+
+			err := s.CheckTimestamp(tt.t, now)
+			if got := errors.Is(err, ErrWebhookTimestampSkewed); got != tt.wantErr {
+				t.Errorf("CheckTimestamp() error = %v, want skewed %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveryReload(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	s := NewWebhookDeliveryService(&config.Config{}, nil)
+
+	if err := s.CheckTimestamp(now.Add(-time.Hour), now); err == nil {
+		t.Fatal("CheckTimestamp() error = nil before reload, want skewed")
+	}
+
+	cfg := &config.Config{}
+	cfg.ServerConfig.WebhookTolerance = 2 * time.Hour
+	s.Reload(cfg)
+	if err := s.CheckTimestamp(now.Add(-time.Hour), now); err != nil {
+		t.Errorf("CheckTimestamp() error = %v after reload, want nil", err)
+	}
+}
@@ -0,0 +1,175 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"message-pocket/internal/config"
+	"net/http"
+	"os"
+
+	"github.com/samber/do/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName instrumentation 名称
+const tracerName = "message-pocket"
+
+// defaultServiceName 未配置 service_name 时上报的服务名
+const defaultServiceName = "message-pocket"
+
+// 导出方式
+const (
+	ExporterNone   = ""
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Provider 全局 TracerProvider，未配置导出时仍生成 trace，便于日志按 trace_id 关联
+type Provider struct {
+	tracerProvider *sdktrace.TracerProvider
+}
+
+// NewProvider 按配置创建 TracerProvider，并设置为全局 TracerProvider 和 W3C traceparent 传播器
+func NewProvider(cfg config.TracingConfig) (*Provider, error) {
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		// 上游已决定采样时沿用上游的决定
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	}
+
+	switch cfg.Exporter {
+	case ExporterNone:
+	case ExporterOTLP:
+		exporterOptions := make([]otlptracehttp.Option, 0, 2)
+		// 未配置时使用 OTEL_EXPORTER_OTLP_ENDPOINT 等环境变量或默认的 localhost:4318
+		if cfg.Endpoint != "" {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), exporterOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: failed to create otlp exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case ExporterStdout:
+		// 输出到标准错误，避免干扰命令行的标准输出
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+		if err != nil {
+			return nil, fmt.Errorf("tracing: failed to create stdout exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithSyncer(exporter))
+	default:
+		return nil, fmt.Errorf("tracing.exporter: unknown exporter %q, expected otlp or stdout", cfg.Exporter)
+	}
+
+	provider := &Provider{tracerProvider: sdktrace.NewTracerProvider(options...)}
+	otel.SetTracerProvider(provider.tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider, nil
+}
+
+func ProvideProvider(i do.Injector) (*Provider, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	return NewProvider(cfg.Tracing)
+}
+
+// Shutdown 导出剩余的 span
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return p.tracerProvider.Shutdown(ctx)
+}
+
+// Start 开始一个 span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// End 结束 span，err 不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract 从请求头读取上游的 traceparent
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject 将当前 trace 写入请求头，传递给下游
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// ContextWithRemoteParent 以保存的 trace ID 和 span ID 作为之后创建的 span 的父 span，ID 无效时返回原 context。
+// traceFlags 为保存的 trace flags，沿用原请求的采样决定；为空时（保存 flags 之前的消息）视为已采样
+func ContextWithRemoteParent(ctx context.Context, traceID, spanID, traceFlags string) context.Context {
+	parsedTraceID, err := trace.TraceIDFromHex(traceID)
+	if err != nil {
+		return ctx
+	}
+	parsedSpanID, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return ctx
+	}
+	flags := trace.FlagsSampled
+	if traceFlags != "" {
+		decoded, err := hex.DecodeString(traceFlags)
+		if err != nil || len(decoded) != 1 {
+			return ctx
+		}
+		flags = trace.TraceFlags(decoded[0])
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    parsedTraceID,
+		SpanID:     parsedSpanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+// TraceID 返回当前 span 的 trace ID，没有 span 时返回空
+func TraceID(ctx context.Context) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	return ""
+}
+
+// SpanID 返回当前 span 的 span ID，没有 span 时返回空
+func SpanID(ctx context.Context) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasSpanID() {
+		return spanContext.SpanID().String()
+	}
+	return ""
+}
+
+// TraceFlags 返回当前 span 的 trace flags（十六进制，如 01 表示已采样），没有 span 时返回空
+func TraceFlags(ctx context.Context) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		return spanContext.TraceFlags().String()
+	}
+	return ""
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
+	"message-pocket/internal/commands"
+	"message-pocket/internal/constants/api_token_enum"
 	"message-pocket/internal/cron"
+	"message-pocket/internal/metrics"
 	"message-pocket/internal/middlewares"
 	"message-pocket/internal/repo"
 	"os"
@@ -12,11 +16,16 @@ import (
 
 	"message-pocket/internal/config"
 	"message-pocket/internal/controllers"
+	"message-pocket/internal/encryption"
+	"message-pocket/internal/redact"
 	"message-pocket/internal/services"
+	"message-pocket/internal/tracing"
 
 	_ "message-pocket/migrations"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 	"github.com/samber/do/v2"
@@ -27,15 +36,17 @@ type ContextHandler struct {
 }
 
 func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
-	// 从 context 中提取 traceID
-	if traceID, ok := ctx.Value("trace_id").(string); ok {
-		r.AddAttrs(slog.String("trace_id", traceID))
+	// 从 context 的当前 span 中提取 trace_id 和 span_id
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		r.AddAttrs(slog.String("trace_id", traceID), slog.String("span_id", tracing.SpanID(ctx)))
 	}
 	return h.Handler.Handle(ctx, r)
 }
 
 func main() {
-	slog.SetDefault(slog.New(&ContextHandler{Handler: slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug})}))
+	// 加载配置前使用内置脱敏规则，加载后替换为配置的规则
+	redactHandler := redact.NewHandler(&ContextHandler{Handler: slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug})}, redact.Default())
+	slog.SetDefault(slog.New(redactHandler))
 
 	app := pocketbase.New()
 
@@ -48,51 +59,196 @@ func main() {
 		Automigrate: isGoRun,
 	})
 
+	// 配置文件路径，与 PocketBase 的 --dir 等参数一样在执行命令前解析，供加载配置使用
+	var configFiles []string
+	app.RootCmd.PersistentFlags().StringSliceVar(&configFiles, "config", nil,
+		"config files, merged in order with later files overriding earlier ones (default ./config.yaml)")
+	_ = app.RootCmd.ParseFlags(os.Args[1:])
+	config.SetFiles(configFiles...)
+
 	injector := Inject(app)
+	// 规则有误时保留内置规则，由 config validate 和使用脱敏的服务报告错误
+	if redactors, err := do.Invoke[*redact.Redactors](injector); err == nil {
+		redactHandler.SetRedactor(redactors.Logs)
+	} else {
+		slog.Error("Invalid redaction config, using built-in rules", "err", err)
+	}
+	// 退出时关闭已创建的服务，如导出剩余的 span、关闭告警 webhook 的连接
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		if report := injector.Shutdown(); !report.Succeed {
+			slog.Error("Failed to shutdown services", "err", report)
+		}
+		return e.Next()
+	})
+	// 导出配置有误时仍生成 trace，只是不导出
+	if _, err := do.Invoke[*tracing.Provider](injector); err != nil {
+		slog.Error("Invalid tracing config, spans will not be exported", "err", err)
+		if _, err := tracing.NewProvider(config.TracingConfig{}); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// 定时任务初始化
 	cron.Init(app, injector)
 
+	// 运维子命令
+	commands.Register(app.RootCmd, injector)
+
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
-		apiGroup := se.Router.Group("api")
+		// 启动前校验配置，一次列出全部错误后退出，避免到第一次发送时才发现
+		// 错误按行输出到 stderr，JSON 日志会把多行合成一个字段
+		cfg, err := config.LoadConfig()
+		if err == nil {
+			err = services.ValidateConfig(cfg)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		apiGroup := se.Router.Group("/api")
 		{
 			eoController := do.MustInvoke[*controllers.EOController](injector)
+			silenceController := do.MustInvoke[*controllers.SilenceController](injector)
+			scheduleController := do.MustInvoke[*controllers.ScheduleController](injector)
+			messageController := do.MustInvoke[*controllers.MessageController](injector)
+			tokenController := do.MustInvoke[*controllers.TokenController](injector)
+			healthController := do.MustInvoke[*controllers.HealthController](injector)
+			tokenService := do.MustInvoke[*services.TokenService](injector)
+			authLockoutService := do.MustInvoke[*services.AuthLockoutService](injector)
+			ipAccessService := do.MustInvoke[*services.IPAccessService](injector)
 			// 添加 Trace 中间件（最先执行）
 			apiGroup.BindFunc(middlewares.TraceMiddleware())
-			// 添加 Token 验证中间件
-			apiGroup.BindFunc(middlewares.TokenAuthMiddleware())
-			// 添加 EO Webhook 路由
-			apiGroup.POST("/eo/webhook", eoController.EOWebhookEvent)
+			// 查询参数中的令牌在写入请求日志前替换为掩码
+			apiGroup.BindFunc(middlewares.MaskQueryTokenMiddleware())
+
+			// 来源接口，令牌需要对应来源的范围
+			// EdgeOne 只能在 URL 中携带令牌，允许 ?token=
+			apiGroup.POST("/eo/webhook", eoController.EOWebhookEvent).
+				BindFunc(middlewares.IPAccessMiddleware(ipAccessService, services.IPAccessGroupSource)).
+				BindFunc(middlewares.TokenAuthMiddleware(tokenService, authLockoutService, middlewares.TokenAuthOptions{
+					Scope:   api_token_enum.ScopeSourceEO,
+					Sources: []middlewares.TokenSource{middlewares.TokenFromBearer, middlewares.TokenFromHeader, middlewares.TokenFromQuery},
+				}))
+
+			// 就绪检查，不需要令牌，只返回状态码，通过 ip_access.status 限制访问来源
+			// 存活检查使用 PocketBase 自带的 /api/health，依赖状态属于管理接口
+			apiGroup.GET("/ready", healthController.Ready).
+				BindFunc(middlewares.IPAccessMiddleware(ipAccessService, services.IPAccessGroupStatus))
+
+			// 管理接口
+			adminGroup := apiGroup.Group("")
+			adminGroup.BindFunc(middlewares.IPAccessMiddleware(ipAccessService, services.IPAccessGroupAdmin))
+			adminGroup.BindFunc(middlewares.TokenAuthMiddleware(tokenService, authLockoutService, middlewares.TokenAuthOptions{
+				Scope: api_token_enum.ScopeAdmin,
+			}))
+			// 添加静默规则路由
+			adminGroup.POST("/silences", silenceController.CreateSilence)
+			adminGroup.GET("/silences", silenceController.ListSilences)
+			adminGroup.DELETE("/silences/{id}", silenceController.ExpireSilence)
+			// 添加定时消息路由
+			adminGroup.POST("/schedules", scheduleController.CreateSchedule)
+			adminGroup.GET("/schedules", scheduleController.ListSchedules)
+			adminGroup.DELETE("/schedules/{id}", scheduleController.CancelSchedule)
+			// 添加消息查询路由
+			adminGroup.GET("/messages", messageController.ListMessages)
+			adminGroup.GET("/messages/{id}", messageController.GetMessage)
+			adminGroup.POST("/messages/{id}/resend", messageController.ResendMessage)
+			adminGroup.POST("/messages/{id}/cancel", messageController.CancelMessage)
+			adminGroup.POST("/messages/retry", messageController.BulkRetryMessages)
+			// 添加令牌管理路由
+			adminGroup.POST("/tokens", tokenController.CreateToken)
+			adminGroup.GET("/tokens", tokenController.ListTokens)
+			adminGroup.POST("/tokens/{id}/rotate", tokenController.RotateToken)
+			adminGroup.DELETE("/tokens/{id}", tokenController.RevokeToken)
+			// 添加依赖状态路由，包含 NapCat 地址和错误信息
+			adminGroup.GET("/status", healthController.Status)
+		}
+
+		// config.yaml 变化时校验并热加载，校验失败保留原配置
+		if reloadService, err := do.Invoke[*services.ConfigReloadService](injector); err == nil {
+			config.Watch(reloadService.Validate, reloadService.Apply)
+		} else {
+			slog.Error("Config hot reload disabled", "err", err)
 		}
 
+		// Prometheus 指标，不经过令牌认证，只允许 ip_access.groups.metrics 中配置的抓取来源
+		metricsHandler := do.MustInvoke[*metrics.Metrics](injector).Handler()
+		se.Router.GET("/metrics", apis.WrapStdHandler(metricsHandler)).
+			BindFunc(middlewares.IPAccessMiddleware(do.MustInvoke[*services.IPAccessService](injector), services.IPAccessGroupMetrics))
+
 		return se.Next()
 	})
 
 	if err := app.Start(); err != nil {
 		log.Fatal(err)
 	}
+	if commands.Err() != nil {
+		os.Exit(1)
+	}
 }
 
 func Inject(app core.App) do.Injector {
 
 	injector := do.New()
 
-	// load config
-	cfg := config.GetConfig()
+	// load config，读取失败时使用空配置，错误由启动前的校验和子命令报告
+	cfg, _ := config.LoadConfig()
 
 	// controller
 	do.Provide(injector, controllers.ProvideEOController)
+	do.Provide(injector, controllers.ProvideSilenceController)
+	do.Provide(injector, controllers.ProvideScheduleController)
+	do.Provide(injector, controllers.ProvideMessageController)
+	do.Provide(injector, controllers.ProvideTokenController)
+	do.Provide(injector, controllers.ProvideHealthController)
 
 	// service
 	do.Provide(injector, services.ProvideEOService)
 	do.Provide(injector, services.ProvideMessageBoxService)
 	do.Provide(injector, services.ProvideNapCatService)
+	do.Provide(injector, services.ProvideRateLimitService)
+	do.Provide(injector, services.ProvideCircuitBreakerService)
+	do.Provide(injector, services.ProvideWatchdogService)
+	do.Provide(injector, services.ProvideMuteService)
+	do.Provide(injector, services.ProvideSeverityService)
+	do.Provide(injector, services.ProvideEODeploymentService)
+	do.Provide(injector, services.ProvideScheduleService)
+	do.Provide(injector, services.ProvideMessageOperationService)
+	do.Provide(injector, services.ProvideTokenService)
+	do.Provide(injector, services.ProvideAuthLockoutService)
+	do.Provide(injector, services.ProvideIPAccessService)
+	do.Provide(injector, services.ProvideConfigReloadService)
+	do.Provide(injector, services.ProvideHealthService)
+	do.Provide(injector, services.ProvideWebhookDeliveryService)
 
 	// repo
 	do.Provide(injector, repo.ProvideMessageBoxRepo)
+	do.MustAs[*repo.MessageBoxRepo, repo.IMessageBoxRepo](injector)
+	do.Provide(injector, repo.ProvideMessageAttemptRepo)
+	do.MustAs[*repo.MessageAttemptRepo, repo.IMessageAttemptRepo](injector)
+	do.Provide(injector, repo.ProvideMessageSilenceRepo)
+	do.MustAs[*repo.MessageSilenceRepo, repo.IMessageSilenceRepo](injector)
+	do.Provide(injector, repo.ProvideEODeploymentRepo)
+	do.MustAs[*repo.EODeploymentRepo, repo.IEODeploymentRepo](injector)
+	do.Provide(injector, repo.ProvideScheduledMessageRepo)
+	do.MustAs[*repo.ScheduledMessageRepo, repo.IScheduledMessageRepo](injector)
+	do.Provide(injector, repo.ProvideAPITokenRepo)
+	do.MustAs[*repo.APITokenRepo, repo.IAPITokenRepo](injector)
+	do.Provide(injector, repo.ProvideWebhookDeliveryRepo)
+	do.MustAs[*repo.WebhookDeliveryRepo, repo.IWebhookDeliveryRepo](injector)
 
 	// other
-	do.ProvideValue(injector, app.DB())
+	do.Provide(injector, encryption.ProvideKeyring)
+	do.Provide(injector, redact.ProvideRedactors)
+	do.Provide(injector, metrics.ProvideMetrics)
+	do.Provide(injector, tracing.ProvideProvider)
+	// app.DB() 在 bootstrap 之后才可用，延迟到首次使用时获取
+	do.Provide(injector, func(i do.Injector) (dbx.Builder, error) {
+		return app.DB(), nil
+	})
+	// 按集合读写的 repo 需要 core.App 以触发 hooks 和 realtime
+	do.ProvideValue[core.App](injector, app)
 	do.ProvideValue(injector, cfg)
 
 	return injector
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+create table if not exists message_silence (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    matchers TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    starts_at INT NOT NULL,
+    ends_at INT NOT NULL,
+    created_at INT NOT NULL
+);
+create index if not exists idx_message_silence_ends_at on message_silence (ends_at);
+`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		_, err := app.DB().NewQuery(`drop table if exists message_silence;`).Execute()
+
+		return err
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+alter table message_box add column severity INT NOT NULL DEFAULT 1;
+create index if not exists idx_message_box_status_severity on message_box (status, severity, created_at);
+`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+drop index if exists idx_message_box_status_severity;
+alter table message_box drop column severity;
+`).Execute()
+
+		return err
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+create table if not exists eo_deployment (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    project_name TEXT NOT NULL DEFAULT '',
+    repo_branch TEXT NOT NULL DEFAULT '',
+    last_event_type TEXT NOT NULL,
+    result TEXT NOT NULL DEFAULT '',
+    fail_streak INT NOT NULL DEFAULT 0,
+    created_at INT NOT NULL,
+    build_started_at INT NOT NULL DEFAULT 0,
+    build_finished_at INT NOT NULL DEFAULT 0,
+    deploy_started_at INT NOT NULL DEFAULT 0,
+    finished_at INT NOT NULL DEFAULT 0,
+    updated_at INT NOT NULL
+);
+create unique index if not exists idx_eo_deployment_project_deployment on eo_deployment (project_id, deployment_id);
+create index if not exists idx_eo_deployment_branch_finished on eo_deployment (project_id, repo_branch, finished_at);
+`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		_, err := app.DB().NewQuery(`drop table if exists eo_deployment;`).Execute()
+
+		return err
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+create table if not exists scheduled_message (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message TEXT NOT NULL,
+    destinations TEXT NOT NULL,
+    severity INT NOT NULL DEFAULT 1,
+    cron_expr TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT '',
+    expire_after INT NOT NULL DEFAULT 0,
+    status INT NOT NULL,
+    next_run_at INT NOT NULL,
+    last_run_at INT NOT NULL DEFAULT 0,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at INT NOT NULL
+);
+create index if not exists idx_scheduled_message_status_next_run on scheduled_message (status, next_run_at);
+alter table message_box add column expires_at INT NOT NULL DEFAULT 0;
+`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+drop table if exists scheduled_message;
+alter table message_box drop column expires_at;
+`).Execute()
+
+		return err
+	})
+}
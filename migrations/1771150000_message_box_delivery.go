@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+alter table message_box add column attempts INT NOT NULL DEFAULT 0;
+alter table message_box add column receipt TEXT NOT NULL DEFAULT '';
+create index if not exists idx_message_box_created_at on message_box (created_at);
+create index if not exists idx_message_box_biz_id on message_box (biz_id);
+`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+drop index if exists idx_message_box_created_at;
+drop index if exists idx_message_box_biz_id;
+alter table message_box drop column attempts;
+alter table message_box drop column receipt;
+`).Execute()
+
+		return err
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+alter table message_box add column target TEXT NOT NULL DEFAULT '';
+alter table message_box add column operation TEXT NOT NULL DEFAULT '';
+alter table message_box add column operated_by TEXT NOT NULL DEFAULT '';
+alter table message_box add column operated_at INT NOT NULL DEFAULT 0;
+`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+alter table message_box drop column target;
+alter table message_box drop column operation;
+alter table message_box drop column operated_by;
+alter table message_box drop column operated_at;
+`).Execute()
+
+		return err
+	})
+}
@@ -0,0 +1,132 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// messageBoxMaxTextLength message、source_request 等长文本字段允许的最大字符数
+const messageBoxMaxTextLength = 1 << 20
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 原表改名保留数据，索引名与新集合冲突需要先删除
+		if _, err := app.DB().NewQuery(`
+drop index if exists idx_message_box_status_severity;
+drop index if exists idx_message_box_created_at;
+drop index if exists idx_message_box_biz_id;
+alter table message_box rename to message_box_legacy;
+`).Execute(); err != nil {
+			return err
+		}
+
+		collection := core.NewBaseCollection("message_box")
+		// 访问规则为 nil，只有超级管理员可以查看和修改
+		collection.ListRule = nil
+		collection.ViewRule = nil
+		collection.CreateRule = nil
+		collection.UpdateRule = nil
+		collection.DeleteRule = nil
+
+		collection.Fields.Add(
+			&core.TextField{Name: "biz_id"},
+			&core.NumberField{Name: "status", Required: true, OnlyInt: true},
+			&core.TextField{Name: "message", Required: true, Max: messageBoxMaxTextLength},
+			&core.TextField{Name: "source_request", Max: messageBoxMaxTextLength},
+			&core.NumberField{Name: "source_type", Required: true, OnlyInt: true},
+			&core.NumberField{Name: "destination_type", Required: true, OnlyInt: true},
+			&core.NumberField{Name: "severity", OnlyInt: true},
+			&core.NumberField{Name: "expires_at", OnlyInt: true},
+			&core.NumberField{Name: "created_at", OnlyInt: true},
+			&core.NumberField{Name: "last_sent_at", OnlyInt: true},
+			&core.TextField{Name: "last_error", Max: messageBoxMaxTextLength},
+			&core.NumberField{Name: "attempts", OnlyInt: true},
+			&core.JSONField{Name: "receipt"},
+			&core.TextField{Name: "target"},
+			&core.TextField{Name: "operation"},
+			&core.TextField{Name: "operated_by"},
+			&core.NumberField{Name: "operated_at", OnlyInt: true},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+
+		collection.AddIndex("idx_message_box_status_severity", false, "status, severity, created_at", "")
+		collection.AddIndex("idx_message_box_created_at", false, "created_at", "")
+		collection.AddIndex("idx_message_box_biz_id", false, "biz_id", "")
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 迁移历史数据，整数 ID 替换为 PocketBase 格式的 15 位 ID
+		_, err := app.DB().NewQuery(`
+insert into message_box (
+    id, biz_id, status, message, source_request, source_type, destination_type, severity,
+    expires_at, created_at, last_sent_at, last_error, attempts, receipt, target,
+    operation, operated_by, operated_at, created, updated
+)
+select
+    'r' || lower(hex(randomblob(7))), biz_id, status, message, source_request, source_type, destination_type, severity,
+    expires_at, created_at, coalesce(last_sent_at, 0), coalesce(last_error, ''), attempts, nullif(receipt, ''), target,
+    operation, operated_by, operated_at,
+    strftime('%Y-%m-%d %H:%M:%S.000Z', created_at, 'unixepoch'),
+    strftime('%Y-%m-%d %H:%M:%S.000Z', max(created_at, coalesce(last_sent_at, 0), operated_at), 'unixepoch')
+from message_box_legacy;
+drop table message_box_legacy;
+`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		// 还原为普通表，消息 ID 重新生成
+		if _, err := app.DB().NewQuery(`
+create table message_box_legacy (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    biz_id TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    message TEXT NOT NULL,
+    source_request TEXT NOT NULL,
+    source_type INT NOT NULL,
+    destination_type INT NOT NULL,
+    created_at INT NOT NULL,
+    last_sent_at INT,
+    last_error TEXT,
+    severity INT NOT NULL DEFAULT 1,
+    expires_at INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    receipt TEXT NOT NULL DEFAULT '',
+    target TEXT NOT NULL DEFAULT '',
+    operation TEXT NOT NULL DEFAULT '',
+    operated_by TEXT NOT NULL DEFAULT '',
+    operated_at INT NOT NULL DEFAULT 0
+);
+insert into message_box_legacy (
+    biz_id, status, message, source_request, source_type, destination_type, created_at, last_sent_at,
+    last_error, severity, expires_at, attempts, receipt, target, operation, operated_by, operated_at
+)
+select
+    biz_id, status, message, source_request, source_type, destination_type, created_at, last_sent_at,
+    last_error, severity, expires_at, attempts, coalesce(receipt, ''), target, operation, operated_by, operated_at
+from message_box
+order by created_at;
+`).Execute(); err != nil {
+			return err
+		}
+
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+		if err := app.Delete(collection); err != nil {
+			return err
+		}
+
+		_, err = app.DB().NewQuery(`
+alter table message_box_legacy rename to message_box;
+create index if not exists idx_message_box_status_severity on message_box (status, severity, created_at);
+create index if not exists idx_message_box_created_at on message_box (created_at);
+create index if not exists idx_message_box_biz_id on message_box (biz_id);
+`).Execute()
+
+		return err
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection := core.NewBaseCollection("api_token")
+		// 访问规则为 nil，只有超级管理员可以查看和修改
+		collection.ListRule = nil
+		collection.ViewRule = nil
+		collection.CreateRule = nil
+		collection.UpdateRule = nil
+		collection.DeleteRule = nil
+
+		collection.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100},
+			&core.TextField{Name: "token_prefix", Required: true},
+			// 哈希不通过 API 返回
+			&core.TextField{Name: "token_hash", Required: true, Hidden: true},
+			&core.JSONField{Name: "scopes"},
+			&core.NumberField{Name: "expires_at", OnlyInt: true},
+			&core.NumberField{Name: "last_used_at", OnlyInt: true},
+			&core.NumberField{Name: "revoked_at", OnlyInt: true},
+			&core.TextField{Name: "created_by"},
+			&core.NumberField{Name: "created_at", OnlyInt: true},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+
+		collection.AddIndex("idx_api_token_name", true, "name", "")
+		collection.AddIndex("idx_api_token_token_hash", true, "token_hash", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("api_token")
+		if err != nil {
+			return err
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		// key_id 为空表示明文，data_key 为主密钥加密后的数据密钥，不通过 API 返回
+		collection.Fields.Add(
+			&core.TextField{Name: "key_id"},
+			&core.TextField{Name: "data_key", Hidden: true},
+		)
+		collection.AddIndex("idx_message_box_key_id", false, "key_id", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_message_box_key_id")
+		collection.Fields.RemoveByName("key_id")
+		collection.Fields.RemoveByName("data_key")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		// 创建消息时的 trace，重试在同一 trace 中进行，span_id 为重试 span 的父 span
+		collection.Fields.Add(
+			&core.TextField{Name: "trace_id"},
+			&core.TextField{Name: "span_id"},
+		)
+		collection.AddIndex("idx_message_box_trace_id", false, "trace_id", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_message_box_trace_id")
+		collection.Fields.RemoveByName("trace_id")
+		collection.Fields.RemoveByName("span_id")
+
+		return app.Save(collection)
+	})
+}
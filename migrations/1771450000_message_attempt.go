@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		messageBox, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		collection := core.NewBaseCollection("message_attempt")
+		// 访问规则为 nil，只有超级管理员可以查看和修改
+		collection.ListRule = nil
+		collection.ViewRule = nil
+		collection.CreateRule = nil
+		collection.UpdateRule = nil
+		collection.DeleteRule = nil
+
+		collection.Fields.Add(
+			// 删除消息时一并删除发送历史
+			&core.RelationField{Name: "message_id", CollectionId: messageBox.Id, MaxSelect: 1, Required: true, CascadeDelete: true},
+			&core.NumberField{Name: "destination_type", Required: true, OnlyInt: true},
+			&core.TextField{Name: "target"},
+			&core.TextField{Name: "trigger"},
+			&core.NumberField{Name: "duration_ms", OnlyInt: true},
+			&core.NumberField{Name: "http_status", OnlyInt: true},
+			&core.NumberField{Name: "retcode", OnlyInt: true},
+			&core.TextField{Name: "error", Max: messageBoxMaxTextLength},
+			&core.TextField{Name: "bot"},
+			&core.NumberField{Name: "created_at", OnlyInt: true},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+
+		collection.AddIndex("idx_message_attempt_message_id", false, "message_id, created_at", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_attempt")
+		if err != nil {
+			return err
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_attempt")
+		if err != nil {
+			return err
+		}
+
+		// 状态接口按目的地查询最近一次成功和失败的发送
+		collection.AddIndex("idx_message_attempt_destination", false, "destination_type, created_at", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_attempt")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_message_attempt_destination")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		// 创建消息时的 trace flags，重试沿用原请求的采样决定，为空的历史消息视为已采样
+		collection.Fields.Add(&core.TextField{Name: "trace_flags"})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("trace_flags")
+
+		return app.Save(collection)
+	})
+}
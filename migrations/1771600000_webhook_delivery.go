@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 来源 webhook 已处理的投递 ID，过期后由定时任务清理
+		_, err := app.DB().NewQuery(`
+create table if not exists webhook_delivery (
+    source_type INT NOT NULL,
+    delivery_id TEXT NOT NULL,
+    created_at INT NOT NULL,
+    expires_at INT NOT NULL,
+    PRIMARY KEY (source_type, delivery_id)
+);
+create index if not exists idx_webhook_delivery_expires_at on webhook_delivery (expires_at);
+`).Execute()
+		if err != nil {
+			return err
+		}
+
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		// 同一来源的幂等键只能保存一条消息，为空表示不去重
+		collection.Fields.Add(&core.TextField{Name: "idempotency_key"})
+		collection.AddIndex("idx_message_box_idempotency_key", true, "source_type, idempotency_key", "idempotency_key != ''")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("message_box")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_message_box_idempotency_key")
+		collection.Fields.RemoveByName("idempotency_key")
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		_, err = app.DB().NewQuery(`drop table if exists webhook_delivery;`).Execute()
+
+		return err
+	})
+}